@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// AssistantsDir holds an assistant per file next to the config file:
+	// <name>.yaml or <name>.yml with its settings, and <name>.md with its
+	// prompt as the body and settings as YAML front matter
+	AssistantsDir = "assistants"
+	// PromptsDir holds a prompt template per file next to the config file:
+	// <name>.md with the template as the body and its description and
+	// assistant as YAML front matter
+	PromptsDir = "prompts"
+)
+
+// assistantFileOrder is the order in which the files of an assistant are
+// applied; later files override earlier ones
+var assistantFileOrder = map[string]int{".yaml": 0, ".yml": 1, ".md": 2}
+
+// mergeFiles merges the assistant and prompt files in dir, the directory of
+// the config file, into config. Settings in files override those of the
+// config file field by field.
+func mergeFiles(config *Config, dir string) error {
+	if err := mergeAssistantFiles(config, filepath.Join(dir, AssistantsDir)); err != nil {
+		return err
+	}
+	return mergePromptFiles(config, filepath.Join(dir, PromptsDir))
+}
+
+// mergeAssistantFiles applies the files in dir to the assistants of config
+func mergeAssistantFiles(config *Config, dir string) error {
+	paths, err := filesByExtension(dir, assistantFileOrder)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 0 && config.Assistants == nil {
+		config.Assistants = make(map[string]AssistantConfig)
+	}
+
+	for _, path := range paths {
+		name := fileName(path)
+		assistant := config.Assistants[name]
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		settings, body := content, ""
+		if filepath.Ext(path) == ".md" {
+			settings, body = splitFrontMatter(content)
+		}
+		if err := applyYAML(settings, &assistant); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if body != "" {
+			assistant.Prompt = body
+		}
+		config.Assistants[name] = assistant
+	}
+	return nil
+}
+
+// mergePromptFiles adds the templates in dir to config
+func mergePromptFiles(config *Config, dir string) error {
+	paths, err := filesByExtension(dir, map[string]int{".md": 0})
+	if err != nil {
+		return err
+	}
+	if len(paths) > 0 && config.Templates == nil {
+		config.Templates = make(map[string]TemplateConfig)
+	}
+
+	for _, path := range paths {
+		name := fileName(path)
+		tmpl := config.Templates[name]
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		settings, body := splitFrontMatter(content)
+		if err := applyYAML(settings, &tmpl); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if body != "" {
+			tmpl.Template = body
+		}
+		config.Templates[name] = tmpl
+	}
+	return nil
+}
+
+// filesByExtension returns the files in dir with one of the extensions,
+// sorted by name and then by the rank of their extension. A missing
+// directory has no files.
+func filesByExtension(dir string, ranks map[string]int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if _, ok := ranks[filepath.Ext(entry.Name())]; ok && !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if fileName(paths[i]) != fileName(paths[j]) {
+			return fileName(paths[i]) < fileName(paths[j])
+		}
+		return ranks[filepath.Ext(paths[i])] < ranks[filepath.Ext(paths[j])]
+	})
+	return paths, nil
+}
+
+// fileName returns the name of a file without directory and extension
+func fileName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// splitFrontMatter splits Markdown into the YAML front matter between
+// leading "---" lines, if any, and the trimmed body
+func splitFrontMatter(content []byte) ([]byte, string) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, strings.TrimSpace(text)
+	}
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---\n")
+	switch {
+	case strings.HasPrefix(rest, "---\n"):
+		return nil, strings.TrimSpace(rest[len("---\n"):])
+	case end >= 0:
+		return []byte(rest[:end]), strings.TrimSpace(rest[end+len("\n---\n"):])
+	case strings.HasSuffix(rest, "\n---"):
+		return []byte(strings.TrimSuffix(rest, "\n---")), ""
+	}
+	return nil, strings.TrimSpace(text)
+}
+
+// applyYAML sets the fields of target from a YAML mapping with the same
+// keys as the JSON config. Unknown keys are an error to catch typos.
+func applyYAML(data []byte, target interface{}) error {
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	converted, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(converted))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
+
+	"llm_cli/llm/api"
 )
 
 // ModelConfig represents the configuration for a single model
@@ -11,13 +15,137 @@ type ModelConfig struct {
 	API     string `json:"API"`
 	Model   string `json:"Model"`
 	API_KEY string `json:"API_KEY"`
+	// BaseURL overrides the provider endpoint; required for "OpenAICompatible"
+	BaseURL     string            `json:"BaseURL,omitempty"`
+	Headers     map[string]string `json:"Headers,omitempty"`
+	QueryParams map[string]string `json:"QueryParams,omitempty"`
+	// Timeout is the request timeout in seconds; MaxAttempts includes the first try
+	Timeout     int `json:"timeout,omitempty"`
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// ContextTokens is the context length of the model; when set, assistant
+	// history is trimmed to fit it (estimated) instead of by chatContextWindow
+	ContextTokens int `json:"contextTokens,omitempty"`
+	// Fallback lists models tried in order when this one fails with a retryable error
+	Fallback []string `json:"fallback,omitempty"`
+	// Price is used to compute the cost of each call; calls are free without it
+	Price *ModelPrice `json:"price,omitempty"`
+	// Budget limits the usage of this model across all assistants
+	Budget *Budget `json:"budget,omitempty"`
+	// StreamUsage and StructuredOutputs enable the usage chunk of streams and
+	// "json_schema" response formats; on for OpenAI, off for OpenAICompatible
+	StreamUsage       *bool `json:"streamUsage,omitempty"`
+	StructuredOutputs *bool `json:"structuredOutputs,omitempty"`
+	// Generation parameters such as "temperature" and "maxTokens"
+	api.GenerationParams
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Cost returns the price of the given token usage in USD
+func (p *ModelPrice) Cost(usage api.Usage) float64 {
+	if p == nil {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1e6
+}
+
+const (
+	BudgetPolicyRefuse = "refuse"
+	BudgetPolicyWarn   = "warn"
+)
+
+// Budget limits the tokens or cost spent per calendar day and month
+type Budget struct {
+	Daily   *BudgetLimit `json:"daily,omitempty"`
+	Monthly *BudgetLimit `json:"monthly,omitempty"`
+	// Policy is "refuse" (default) to reject calls over budget, or "warn"
+	Policy string `json:"policy,omitempty"`
+}
+
+// BudgetLimit caps total tokens and/or cost in USD; zero means unlimited
+type BudgetLimit struct {
+	Tokens int     `json:"tokens,omitempty"`
+	Cost   float64 `json:"cost,omitempty"`
+}
+
+// SummarizeConfig controls the running summary of an assistant's sessions.
+// Zero values select the defaults.
+type SummarizeConfig struct {
+	// Model writes the summaries; defaults to the assistant's model
+	Model string `json:"model,omitempty"`
+	// After is the number of unsummarized messages that triggers a summary (default 20)
+	After int `json:"after,omitempty"`
+	// Keep is the number of recent messages left out of the summary
+	// (default chatContextWindow*2, at least 4)
+	Keep int `json:"keep,omitempty"`
+	// Prompt replaces the default instructions for the summarizing model
+	Prompt string `json:"prompt,omitempty"`
 }
 
 // AssistantConfig represents the configuration for an assistant
 type AssistantConfig struct {
-	Model             string `json:"model"`
+	Model string `json:"model"`
 	Prompt            string `json:"prompt"`
 	ChatContextWindow int    `json:"chatContextWindow"`
+	// PromptTemplate renders Prompt as a template (see llm.RenderPrompt);
+	// otherwise it is sent as written
+	PromptTemplate bool `json:"promptTemplate,omitempty"`
+	// Budget limits the usage of this assistant across all models
+	Budget *Budget `json:"budget,omitempty"`
+	// InputTemplate joins a command-line instruction with piped input, using
+	// {{.Instruction}} and {{.Input}}; empty uses llm.DefaultInputTemplate
+	InputTemplate string `json:"inputTemplate,omitempty"`
+	// Summarize enables condensing older turns into a running summary
+	Summarize *SummarizeConfig `json:"summarize,omitempty"`
+	// Tools are the names of the built-in or declared tools the assistant may call
+	Tools []string `json:"tools,omitempty"`
+	// AllowedCommands are the programs the run_command tool may start
+	AllowedCommands []string `json:"allowedCommands,omitempty"`
+	// MCPServers are the names of the MCP servers whose tools and resources
+	// the assistant may use
+	MCPServers []string `json:"mcpServers,omitempty"`
+	// Generation parameters, overriding those of the model
+	api.GenerationParams
+}
+
+// ToolConfig declares a tool that runs a shell command
+type ToolConfig struct {
+	Description string `json:"description"`
+	// Parameters is the JSON Schema of the arguments, an object whose
+	// properties can be used in Command
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Command is run by the shell with each {{name}} standing for the
+	// argument of that name, e.g. "./scripts/jira.sh {{id}}"
+	Command string `json:"command"`
+	// Timeout is how long the command may run in seconds (default 30)
+	Timeout int `json:"timeout,omitempty"`
+	// SideEffects makes llmcli ask for confirmation before each call
+	SideEffects bool `json:"sideEffects,omitempty"`
+}
+
+// TemplateConfig is a reusable prompt, rendered as a template into the
+// message sent to an assistant
+type TemplateConfig struct {
+	Description string `json:"description,omitempty"`
+	// Assistant answers the message; empty uses the default assistant
+	Assistant string `json:"assistant,omitempty"`
+	Template  string `json:"template"`
+}
+
+// MCPServerConfig launches a Model Context Protocol server that talks
+// JSON-RPC over its stdin and stdout
+type MCPServerConfig struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Env is added to the environment of the server; values may refer to
+	// variables of llmcli's environment as $NAME or ${NAME}
+	Env map[string]string `json:"env,omitempty"`
+	// Timeout is how long to wait for each reply in seconds (default 30)
+	Timeout int `json:"timeout,omitempty"`
 }
 
 // Config represents the root configuration structure
@@ -25,6 +153,15 @@ type Config struct {
 	Default    string                     `json:"default"`
 	Models     map[string]ModelConfig     `json:"models"`
 	Assistants map[string]AssistantConfig `json:"assistants"`
+	// Tools are tools declared by the user, callable by the assistants that list them
+	Tools map[string]ToolConfig `json:"tools,omitempty"`
+	// MCPServers are Model Context Protocol servers by name
+	MCPServers map[string]MCPServerConfig `json:"mcpServers,omitempty"`
+	// Templates are prompts by name, used with llmcli -t <name>
+	Templates map[string]TemplateConfig `json:"templates,omitempty"`
+	// PromptFiles are the paths or globs templates may include with
+	// {{file "path"}}; relative ones are resolved against the working directory
+	PromptFiles []string `json:"promptFiles,omitempty"`
 }
 
 var (
@@ -40,16 +177,30 @@ func GetConfig() (*Config, error) {
 		var err error
 		instance, err = LoadConfig(configPath)
 		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring config: %v\n", err)
 			instance = &Config{
 				Models:     make(map[string]ModelConfig),
 				Assistants: make(map[string]AssistantConfig),
 			}
 		}
 	})
+
+	mu.RLock()
+	defer mu.RUnlock()
 	return instance, nil
 }
 
-// LoadConfig loads and parses the configuration file
+// SetConfig replaces the configuration returned by GetConfig, e.g. to run
+// against an in-memory configuration in tests
+func SetConfig(cfg *Config) {
+	once.Do(func() {})
+	mu.Lock()
+	defer mu.Unlock()
+	instance = cfg
+}
+
+// LoadConfig loads and parses the configuration file, then merges the
+// assistant and prompt files in the AssistantsDir and PromptsDir next to it
 func LoadConfig(configPath string) (*Config, error) {
 	content, err := os.ReadFile(configPath)
 	if err != nil {
@@ -60,6 +211,9 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := json.Unmarshal(content, &config); err != nil {
 		return nil, err
 	}
+	if err := mergeFiles(&config, filepath.Dir(configPath)); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"llm_cli/utils"
+)
+
+const exportHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>llmcli chat history</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+.message { margin: 1em 0; padding: 0.5em 1em; border-radius: 6px; }
+.user { background: #eef4fb; }
+.assistant { background: #f0f7ee; }
+.meta { color: #777; font-size: 0.85em; }
+pre { white-space: pre-wrap; font-family: inherit; margin: 0.5em 0 0; }
+</style>
+</head>
+<body>
+{{- range .}}
+<h2>{{.Assistant}} / {{.Session}}</h2>
+{{- range .Records}}
+<div class="message {{.Role}}">
+<div class="meta">{{.Role}}{{if .Model}} ({{.Model}}){{end}} · {{.CreatedAt.Local.Format "2006-01-02 15:04"}} · #{{.ID}}</div>
+<pre>{{.Content}}</pre>
+{{- range .Images}}
+<div class="meta">image: {{.}}</div>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`
+
+var exportTemplate = template.Must(template.New("export").Parse(exportHTML))
+
+// exportSession groups the exported records of one session
+type exportSession struct {
+	Assistant string
+	Session   string
+	Records   []utils.ExportRecord
+}
+
+func handleExport(args []string, flags callFlags) {
+	assistantName, format, output := "", "markdown", ""
+	options := map[string]*string{
+		"-a": &assistantName, "--assistant": &assistantName,
+		"--format": &format,
+		"-o":       &output, "--output": &output,
+	}
+	for i := 0; i < len(args); i++ {
+		dst, known := options[args[i]]
+		if !known {
+			fmt.Printf("Error: unknown export option %s\n", args[i])
+			fmt.Println("Usage: llmcli export [--assistant <name>] [--session <name>] [--format markdown|json|jsonl|html] [-o <file>]")
+			return
+		}
+		if i+1 >= len(args) {
+			fmt.Printf("Error: %s requires a value\n", args[i])
+			return
+		}
+		*dst = args[i+1]
+		i++
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	records, err := history.ExportRecords(assistantName, flags.Session)
+	if err != nil {
+		fmt.Printf("Error exporting history: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No chat history to export")
+		return
+	}
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			fmt.Printf("Error creating %s: %v\n", output, err)
+			return
+		}
+		defer out.Close()
+	}
+
+	if err := writeExport(out, records, format); err != nil {
+		fmt.Printf("Error exporting history: %v\n", err)
+		return
+	}
+	if output != "" {
+		fmt.Printf("Exported %d messages to %s\n", len(records), output)
+	}
+}
+
+// writeExport writes records to w in the given format
+func writeExport(w io.Writer, records []utils.ExportRecord, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "markdown", "md":
+		for _, session := range groupSessions(records) {
+			fmt.Fprintf(w, "# %s / %s\n\n", session.Assistant, session.Session)
+			for _, record := range session.Records {
+				role := record.Role
+				if record.Model != "" {
+					role += " (" + record.Model + ")"
+				}
+				fmt.Fprintf(w, "## %s · %s\n\n%s\n\n", role, record.CreatedAt.Local().Format("2006-01-02 15:04"), record.Content)
+				for _, image := range record.Images {
+					fmt.Fprintf(w, "![image](%s)\n\n", image)
+				}
+			}
+		}
+		return nil
+	case "html":
+		return exportTemplate.Execute(w, groupSessions(records))
+	}
+	return fmt.Errorf("unknown format %q, use markdown, json, jsonl or html", format)
+}
+
+// groupSessions splits records sorted by assistant and session into sessions
+func groupSessions(records []utils.ExportRecord) []exportSession {
+	var sessions []exportSession
+	for _, record := range records {
+		last := len(sessions) - 1
+		if last < 0 || sessions[last].Assistant != record.Assistant || sessions[last].Session != record.Session {
+			sessions = append(sessions, exportSession{Assistant: record.Assistant, Session: record.Session})
+			last++
+		}
+		sessions[last].Records = append(sessions[last].Records, record)
+	}
+	return sessions
+}
+
+func handleImport(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: File required")
+		fmt.Println("Usage: llmcli import <file.json|file.jsonl|->")
+		return
+	}
+
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error opening %s: %v\n", args[0], err)
+			return
+		}
+		defer file.Close()
+		in = file
+	}
+
+	records, err := readExport(in)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[0], err)
+		return
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	imported, err := history.ImportRecords(records)
+	if err != nil {
+		fmt.Printf("Error importing history: %v\n", err)
+		return
+	}
+	fmt.Printf("Imported %d of %d messages (%d already present)\n", imported, len(records), len(records)-imported)
+}
+
+// readExport reads records written by export as a JSON array or as JSON lines
+func readExport(r io.Reader) ([]utils.ExportRecord, error) {
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("empty input")
+		}
+		if !strings.ContainsAny(string(b), " \t\r\n") {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	var records []utils.ExportRecord
+	decoder := json.NewDecoder(reader)
+	if b, _ := reader.Peek(1); b[0] == '[' {
+		if err := decoder.Decode(&records); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		return records, nil
+	}
+
+	for line := 1; ; line++ {
+		var record utils.ExportRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON in record %d: %v", line, err)
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+)
+
+// callFlags holds the long options of the command line. They may appear
+// anywhere before the prompt, see promptStarts.
+type callFlags struct {
+	Session        string
+	NewSession     bool
+	NewSessionName string
+	Resume         string
+	// Params are generation parameters overriding the model and assistant config
+	Params api.GenerationParams
+	// Files are paths, directories or globs attached to the prompt
+	Files []string
+	// Images are paths or URLs of images attached to the prompt
+	Images []string
+	// JSON asks for a reply that is valid JSON, matching Schema if set
+	JSON   bool
+	Schema *utils.JSONSchema
+	// Vars are the variables of prompt templates
+	Vars map[string]string
+	// Since and By select the period and grouping of the usage report
+	Since time.Time
+	By    string
+}
+
+// switchesSession reports whether the flags start or resume a session
+func (f callFlags) switchesSession() bool {
+	return f.NewSession || f.Resume != ""
+}
+
+// flagSpecs are the long options of callFlags
+var flagSpecs = map[string]utils.OptionSpec[callFlags]{
+	"--session": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Session = v
+		return nil
+	}},
+	"--new-session": {Optional: true, Set: func(f *callFlags, v string) error {
+		f.NewSession = true
+		f.NewSessionName = v
+		return nil
+	}},
+	"--resume": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Resume = v
+		return nil
+	}},
+	"--temperature": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseFloat(v, &f.Params.Temperature)
+	}},
+	"--top-p": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseFloat(v, &f.Params.TopP)
+	}},
+	"--max-tokens": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseInt(v, &f.Params.MaxTokens)
+	}},
+	"--stop": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Params.Stop = append(f.Params.Stop, v)
+		return nil
+	}},
+	"--seed": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseInt(v, &f.Params.Seed)
+	}},
+	"--presence-penalty": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseFloat(v, &f.Params.PresencePenalty)
+	}},
+	"--frequency-penalty": {Value: true, Set: func(f *callFlags, v string) error {
+		return parseFloat(v, &f.Params.FrequencyPenalty)
+	}},
+	"--response-format": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Params.ResponseFormat = v
+		return nil
+	}},
+	"-f": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Files = append(f.Files, v)
+		return nil
+	}},
+	"--file": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Files = append(f.Files, v)
+		return nil
+	}},
+	"--image": {Value: true, Set: func(f *callFlags, v string) error {
+		f.Images = append(f.Images, v)
+		return nil
+	}},
+	"--json": {Set: func(f *callFlags, v string) error {
+		f.JSON = true
+		return nil
+	}},
+	"--schema": {Value: true, Set: func(f *callFlags, v string) error {
+		schema, err := utils.LoadJSONSchema(v)
+		if err != nil {
+			return err
+		}
+		f.JSON = true
+		f.Schema = schema
+		return nil
+	}},
+	"--var": {Value: true, Set: func(f *callFlags, v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%q is not of the form name=value", v)
+		}
+		if f.Vars == nil {
+			f.Vars = make(map[string]string)
+		}
+		f.Vars[strings.TrimSpace(name)] = value
+		return nil
+	}},
+	"--since": {Value: true, Set: func(f *callFlags, v string) error {
+		since, err := parseSince(v, time.Now())
+		if err != nil {
+			return err
+		}
+		f.Since = since
+		return nil
+	}},
+	"--by": {Value: true, Set: func(f *callFlags, v string) error {
+		switch v {
+		case "model", "assistant", "day":
+			f.By = v
+			return nil
+		}
+		return fmt.Errorf("%q is not one of model, assistant or day", v)
+	}},
+}
+
+func parseFloat(v string, dst **float64) error {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", v)
+	}
+	*dst = &n
+	return nil
+}
+
+func parseInt(v string, dst **int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", v)
+	}
+	*dst = &n
+	return nil
+}
+
+// parseSince parses a period such as "7d", "2w" or "12h" counted back from
+// now, or a date in YYYY-MM-DD form
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return date, nil
+	}
+
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(v, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(v, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(v[:len(v)-1])
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a period like 7d or 2w", v)
+		}
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a period like 7d, 12h or a YYYY-MM-DD date", v)
+	}
+	return now.Add(-d), nil
+}
+
+// commandWords are the commands that are not spelled as options
+var commandWords = map[string]bool{
+	"config": true, "usage": true, "search": true, "export": true, "import": true,
+}
+
+// promptStarts reports whether arg, following the positional arguments in
+// positional, is the first word of a prompt: the word after the name of
+// "-a", "-m" or "-t", or the first word of a call of the default assistant.
+// Options are not looked for in the prompt, so it may contain words like -f.
+func promptStarts(positional []string, arg string) bool {
+	if len(positional) == 0 {
+		return !strings.HasPrefix(arg, "-") && !commandWords[arg]
+	}
+	switch positional[0] {
+	case "-a", "--assistant", "-m", "--model", "-t", "--template":
+		return len(positional) == 2
+	}
+	return false
+}
+
+// parseFlags extracts the options in flagSpecs from args and returns them
+// together with the remaining positional arguments. Options end at "--" or
+// at the first word of the prompt.
+func parseFlags(args []string) (callFlags, []string, error) {
+	var flags callFlags
+	rest, err := utils.ParseOptions(args, &flags, flagSpecs, promptStarts)
+	if err != nil {
+		return flags, nil, err
+	}
+
+	if flags.NewSession && flags.Resume != "" {
+		return flags, nil, fmt.Errorf("--new-session and --resume cannot be combined")
+	}
+	if err := flags.Params.Validate(); err != nil {
+		return flags, nil, err
+	}
+	return flags, rest, nil
+}
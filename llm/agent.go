@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"fmt"
+	"llm_cli/llm/api"
+	"llm_cli/llm/tools"
+	"llm_cli/utils"
+	"os"
+	"sort"
+)
+
+// maxToolRounds bounds how often a model may call tools before answering
+const maxToolRounds = 10
+
+// ConfirmFunc asks the user whether a tool call may run
+type ConfirmFunc func(prompt string) bool
+
+// toolRunner runs the tool calls of an assistant and records them in history
+type toolRunner struct {
+	tools     map[string]tools.Tool
+	confirm   ConfirmFunc
+	history   *utils.History
+	assistant string
+	session   string
+	// before is called before each round of tool calls, if set
+	before func()
+}
+
+// definitions returns the definitions of the tools sent to the model
+func (r *toolRunner) definitions() []api.Tool {
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var definitions []api.Tool
+	for _, name := range names {
+		definitions = append(definitions, r.tools[name].Definition())
+	}
+	return definitions
+}
+
+// callWithTools makes the call and, as long as the model asks for tools,
+// runs them and calls again with their results. Failing tools do not end
+// the loop: the error is sent back for the model to deal with.
+func callWithTools(messages []api.Message, runner *toolRunner, call callFunc) (api.Response, string, error) {
+	if len(runner.tools) == 0 {
+		return call(messages)
+	}
+
+	messages = append([]api.Message{}, messages...)
+	for round := 0; ; round++ {
+		response, answeredBy, err := call(messages)
+		if err != nil || len(response.ToolCalls) == 0 {
+			return response, answeredBy, err
+		}
+		if round == maxToolRounds {
+			return api.Response{}, "", fmt.Errorf("model '%s' still calls tools after %d rounds", answeredBy, maxToolRounds)
+		}
+
+		if runner.before != nil {
+			runner.before()
+		}
+		messages = append(messages, api.Message{Role: "assistant", Content: response.Content, ToolCalls: response.ToolCalls})
+		for _, toolCall := range response.ToolCalls {
+			messages = append(messages, api.Message{
+				Role:       "tool",
+				Content:    runner.run(toolCall),
+				ToolCallID: toolCall.ID,
+			})
+		}
+	}
+}
+
+// run runs one tool call and returns the result for the model
+func (r *toolRunner) run(call api.ToolCall) string {
+	output, err := r.execute(call)
+
+	record := utils.ToolCallRecord{
+		Assistant: r.assistant,
+		Session:   r.session,
+		Tool:      call.Name,
+		Arguments: call.Arguments,
+		Output:    output,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := r.history.AddToolCall(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return output
+}
+
+// execute asks for confirmation if needed and runs the tool
+func (r *toolRunner) execute(call api.ToolCall) (string, error) {
+	tool, ok := r.tools[call.Name]
+	if !ok {
+		return "", fmt.Errorf("there is no tool named '%s'", call.Name)
+	}
+
+	if tool.SideEffects() {
+		if r.confirm == nil || !r.confirm(fmt.Sprintf("Run %s %s?", call.Name, call.Arguments)) {
+			fmt.Fprintf(os.Stderr, "Declined %s %s\n", call.Name, call.Arguments)
+			return "", fmt.Errorf("the user declined to run this tool call")
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Running %s %s\n", call.Name, call.Arguments)
+	}
+	return tool.Run(call.Arguments)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ANTHROPIC_API     = "https://api.anthropic.com/v1/messages"
+	anthropicVersion  = "2023-06-01"
+	anthropicMessages = "/messages"
+	// anthropicMaxTokens is sent when no max_tokens parameter is set,
+	// since the Messages API requires max_tokens on every request
+	anthropicMaxTokens = 4096
+)
+
+type AnthropicProvider struct {
+	BaseProvider
+}
+
+type AnthropicRequest struct {
+	Model         string    `json:"model"`
+	System        string    `json:"system,omitempty"`
+	Messages      []Message `json:"messages"`
+	MaxTokens     int       `json:"max_tokens"`
+	Stream        bool      `json:"stream,omitempty"`
+	Temperature   *float64  `json:"temperature,omitempty"`
+	TopP          *float64  `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+}
+
+type AnthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+	Error      struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicStreamEvent is a single server-sent event of a streamed message.
+// Input tokens are reported in message_start, output tokens in message_delta.
+type AnthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicProvider) Call(req Request) (Response, error) {
+	reqBody, err := p.newRequest(req, false)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := p.send(req, reqBody)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var response AnthropicResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	var content strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+
+	return Response{
+		Content: content.String(),
+		Usage: Usage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// Stream sends the request with "stream": true and forwards text deltas to onDelta
+func (p *AnthropicProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	reqBody, err := p.newRequest(req, true)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp, err := p.send(req, reqBody)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage Usage
+	err = readSSE(resp.Body, func(data string) error {
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
+		}
+
+		switch event.Type {
+		case "error":
+			return fmt.Errorf("API error: %s", anthropicErrorMessage(event.Error.Type, event.Error.Message))
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				return nil
+			}
+			content.WriteString(event.Delta.Text)
+			if onDelta != nil {
+				onDelta(event.Delta.Text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Response{Content: content.String(), Usage: usage}, err
+	}
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: content.String(), Usage: usage}, nil
+}
+
+// newRequest converts the chat messages to the Messages API format, which
+// takes the system prompt as a top-level field rather than a message
+func (p *AnthropicProvider) newRequest(req Request, stream bool) (AnthropicRequest, error) {
+	if err := req.Params.checkSupported(p.Name, "temperature", "top_p", "max_tokens", "stop"); err != nil {
+		return AnthropicRequest{}, err
+	}
+	if err := checkNoImages(p.Name, req.Messages); err != nil {
+		return AnthropicRequest{}, err
+	}
+	if err := checkNoTools(p.Name, req); err != nil {
+		return AnthropicRequest{}, err
+	}
+	if req.Params.Temperature != nil && *req.Params.Temperature > 1 {
+		return AnthropicRequest{}, fmt.Errorf("%s provider requires temperature between 0 and 1", p.Name)
+	}
+
+	maxTokens := anthropicMaxTokens
+	if req.Params.MaxTokens != nil {
+		maxTokens = *req.Params.MaxTokens
+	}
+
+	var system []string
+	messages := make([]Message, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			if message.Content != "" {
+				system = append(system, message.Content)
+			}
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	return AnthropicRequest{
+		Model:         req.Model,
+		System:        strings.Join(system, "\n\n"),
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Stream:        stream,
+		Temperature:   req.Params.Temperature,
+		TopP:          req.Params.TopP,
+		StopSequences: req.Params.Stop,
+	}, nil
+}
+
+// send posts the request and returns the response if the API reported success
+func (p *AnthropicProvider) send(req Request, reqBody AnthropicRequest) (*http.Response, error) {
+	endpoint, err := endpointURL(req, ANTHROPIC_API, anthropicMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := newJSONRequestWithAuth(endpoint, reqBody, req, "x-api-key", "")
+	if err != nil {
+		return nil, err
+	}
+	if httpReq.Header.Get("anthropic-version") == "" {
+		httpReq.Header.Set("anthropic-version", anthropicVersion)
+	}
+
+	resp, err := doRequest(httpReq, req, reqBody.Stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+
+		var errorResp AnthropicResponse
+		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+			return nil, statusError(resp.StatusCode, "API error: %s", anthropicErrorMessage(errorResp.Error.Type, errorResp.Error.Message))
+		}
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// anthropicErrorMessage formats an error body such as
+// {"type": "overloaded_error", "message": "Overloaded"}
+func anthropicErrorMessage(errorType, message string) string {
+	if errorType == "" {
+		return message
+	}
+	return fmt.Sprintf("%s (%s)", message, errorType)
+}
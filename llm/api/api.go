@@ -1,8 +1,27 @@
 package api
 
+import "time"
+
 // LLMProvider defines the interface for LLM providers
 type LLMProvider interface {
-	Call(model string, messages []Message, apiKey string) (string, error)
+	Call(req Request) (Response, error)
+}
+
+// StreamHandler receives each content delta as it arrives from a provider
+type StreamHandler func(delta string)
+
+// StreamProvider is implemented by providers that can stream partial output.
+// Stream returns the full response once the stream completes.
+type StreamProvider interface {
+	LLMProvider
+	Stream(req Request, onDelta StreamHandler) (Response, error)
+}
+
+// ModelLister is implemented by providers that can report which models
+// are available, so configured model names can be validated
+type ModelLister interface {
+	ListModels(req Request) ([]string, error)
+	HasModel(available []string, model string) bool
 }
 
 // BaseProvider implements common functionality
@@ -14,10 +33,65 @@ type BaseProvider struct {
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Images are sent along with the text content to vision models
+	Images []Image `json:"-"`
+	// ToolCalls are the tools an assistant message asks to run
+	ToolCalls []ToolCall `json:"-"`
+	// ToolCallID links a "tool" message with a result to the call it answers
+	ToolCallID string `json:"-"`
+}
+
+// Usage is the token consumption reported by a provider for one call
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// TotalTokens returns the sum of prompt and completion tokens
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Response is the result of a chat completion
+type Response struct {
+	Content string
+	// Usage is zero if the provider did not report it
+	Usage Usage
+	// ToolCalls are the tools the model asks to run before it answers
+	ToolCalls []ToolCall
+}
+
+// Request holds everything a provider needs for a single chat completion
+type Request struct {
+	Model    string
+	Messages []Message
+	APIKey   string
+	// BaseURL overrides the provider's default endpoint, e.g. "http://localhost:8000/v1"
+	BaseURL string
+	// Headers are added to the HTTP request, e.g. {"api-key": "..."} for Azure
+	Headers map[string]string
+	// QueryParams are appended to the endpoint URL, e.g. {"api-version": "2024-06-01"}
+	QueryParams map[string]string
+	// Params are the generation parameters to forward to the model
+	Params GenerationParams
+	// Tools are the functions the model may call
+	Tools []Tool
+	// Timeout and MaxAttempts override DefaultTimeout and DefaultMaxAttempts
+	Timeout     time.Duration
+	MaxAttempts int
+	// StreamUsage and StructuredOutputs enable the "stream_options" usage
+	// chunk and "json_schema" response format of OpenAI-style APIs; nil
+	// selects the provider's default
+	StreamUsage       *bool
+	StructuredOutputs *bool
 }
 
 // Provider map to store available providers
 var Providers = map[string]LLMProvider{
-	"OpenAI":   &OpenAIProvider{BaseProvider{Name: "OpenAI"}},
-	"ChatGLM":  &ChatGLMProvider{BaseProvider{Name: "ChatGLM"}},
-} 
\ No newline at end of file
+	"OpenAI":           &OpenAIProvider{BaseProvider{Name: "OpenAI"}},
+	"ChatGLM":          &ChatGLMProvider{BaseProvider{Name: "ChatGLM"}},
+	"OpenAICompatible": &OpenAICompatibleProvider{OpenAIProvider{BaseProvider{Name: "OpenAICompatible"}}},
+	"Anthropic":        &AnthropicProvider{BaseProvider{Name: "Anthropic"}},
+	"Gemini":           &GeminiProvider{BaseProvider{Name: "Gemini"}},
+	"Ollama":           &OllamaProvider{BaseProvider{Name: "Ollama"}},
+}
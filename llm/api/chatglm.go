@@ -1,7 +1,6 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,61 +14,125 @@ type ChatGLMProvider struct {
 }
 
 type ChatGLMRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model          string                `json:"model"`
+	Messages       []chatMessage         `json:"messages"`
+	Stream         bool                  `json:"stream,omitempty"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	MaxTokens      *int                  `json:"max_tokens,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Tools          []openAITool          `json:"tools,omitempty"`
 }
 
 type ChatGLMResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *ChatCompletionUsage `json:"usage"`
 }
 
-func (p *ChatGLMProvider) Call(model string, messages []Message, apiKey string) (string, error) {
-	reqBody := ChatGLMRequest{
-		Model:    model,
-		Messages: messages,
+// SupportsJSONMode reports that replies can be constrained to JSON, as a JSON object; schemas are checked locally only
+func (p *ChatGLMProvider) SupportsJSONMode() bool {
+	return true
+}
+
+func (p *ChatGLMProvider) Call(req Request) (Response, error) {
+	reqBody, err := p.newRequest(req, false)
+	if err != nil {
+		return Response{}, err
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	resp, err := p.send(req, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return Response{}, err
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequest("POST", CHATGLM_API, bytes.NewBuffer(jsonData))
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var response ChatGLMResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if len(response.Choices) > 0 {
+		return Response{
+			Content:   response.Choices[0].Message.Content,
+			Usage:     response.Usage.usage(),
+			ToolCalls: toolCalls(response.Choices[0].Message.ToolCalls),
+		}, nil
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	return Response{}, fmt.Errorf("no response content")
+}
+
+// Stream sends the request with "stream": true and forwards content deltas to onDelta
+func (p *ChatGLMProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	reqBody, err := p.newRequest(req, true)
+	if err != nil {
+		return Response{}, err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := p.send(req, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return Response{}, err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return readChatCompletionStream(resp.Body, onDelta)
+}
+
+// newRequest builds the request body, rejecting parameters the API does not accept
+func (p *ChatGLMProvider) newRequest(req Request, stream bool) (ChatGLMRequest, error) {
+	if err := req.Params.checkSupported(p.Name, "temperature", "top_p", "max_tokens", "stop", "response_format"); err != nil {
+		return ChatGLMRequest{}, err
+	}
+
+	return ChatGLMRequest{
+		Model:          req.Model,
+		Messages:       chatMessages(req.Messages, true),
+		Stream:         stream,
+		Temperature:    req.Params.Temperature,
+		TopP:           req.Params.TopP,
+		MaxTokens:      req.Params.MaxTokens,
+		Stop:           req.Params.Stop,
+		ResponseFormat: req.Params.openAIResponseFormat(false),
+		Tools:          openAITools(req.Tools),
+	}, nil
+}
+
+// send posts the request and returns the response if the API reported success
+func (p *ChatGLMProvider) send(req Request, reqBody ChatGLMRequest) (*http.Response, error) {
+	endpoint, err := endpointURL(req, CHATGLM_API, chatCompletionsPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	httpReq, err := newJSONRequest(endpoint, reqBody, req)
+	if err != nil {
+		return nil, err
 	}
 
-	var response ChatGLMResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+	resp, err := doRequest(httpReq, req, reqBody.Stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
-	if len(response.Choices) > 0 {
-		return response.Choices[0].Message.Content, nil
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return "", fmt.Errorf("no response content")
-} 
\ No newline at end of file
+	return resp, nil
+}
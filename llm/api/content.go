@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxImageSize is the largest local image that is attached
+const MaxImageSize = 20 * 1024 * 1024
+
+// Image is an image attached to a message for vision models
+type Image struct {
+	// Source is the path or URL the image was given as, kept in history
+	Source string
+	// URL is an http(s) URL or a data URL with the base64-encoded file
+	URL string
+}
+
+// NewImage attaches an http(s) URL as is and reads a local file into a data URL
+func NewImage(source string) (Image, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return Image{Source: source, URL: source}, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %v", err)
+	}
+	if len(data) > MaxImageSize {
+		return Image{}, fmt.Errorf("image %s is larger than %d MB", source, MaxImageSize/1024/1024)
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return Image{}, fmt.Errorf("%s is not an image (%s)", source, mediaType)
+	}
+
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+	return Image{
+		Source: source,
+		URL:    "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// ContentPart is one part of the multimodal content of a chat completions message
+type ContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *ImageURLPart `json:"image_url,omitempty"`
+}
+
+// ImageURLPart holds the URL, or base64 data, of an image content part
+type ImageURLPart struct {
+	URL string `json:"url"`
+}
+
+// chatMessage is a message of the chat completions format. Content is a
+// string, or a list of ContentPart when the message has images.
+type chatMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// chatMessages converts messages to the chat completions format. With
+// rawBase64, data URLs are sent as plain base64 as ChatGLM expects.
+func chatMessages(messages []Message, rawBase64 bool) []chatMessage {
+	converted := make([]chatMessage, len(messages))
+	for i, message := range messages {
+		if len(message.Images) == 0 {
+			converted[i] = chatMessage{
+				Role:       message.Role,
+				Content:    message.Content,
+				ToolCalls:  openAIToolCalls(message.ToolCalls),
+				ToolCallID: message.ToolCallID,
+			}
+			continue
+		}
+
+		var parts []ContentPart
+		if message.Content != "" {
+			parts = append(parts, ContentPart{Type: "text", Text: message.Content})
+		}
+		for _, image := range message.Images {
+			url := image.URL
+			if rawBase64 && strings.HasPrefix(url, "data:") {
+				url = url[strings.Index(url, ",")+1:]
+			}
+			parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURLPart{URL: url}})
+		}
+		converted[i] = chatMessage{Role: message.Role, Content: parts}
+	}
+	return converted
+}
+
+// checkNoImages returns an error if a message has images the provider cannot send
+func checkNoImages(provider string, messages []Message) error {
+	for _, message := range messages {
+		if len(message.Images) > 0 {
+			return fmt.Errorf("%s provider does not support image input", provider)
+		}
+	}
+	return nil
+}
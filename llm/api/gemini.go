@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const GEMINI_API = "https://generativelanguage.googleapis.com/v1beta"
+
+type GeminiProvider struct {
+	BaseProvider
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+	// ResponseJSONSchema constrains a JSON response to a schema
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type GeminiResponse struct {
+	Candidates []struct {
+		Content       GeminiContent        `json:"content"`
+		FinishReason  string               `json:"finishReason"`
+		SafetyRatings []GeminiSafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string               `json:"blockReason"`
+		SafetyRatings []GeminiSafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// SafetyBlockError is returned when Gemini refuses to answer because the
+// prompt or the generated response was blocked by its safety filters
+type SafetyBlockError struct {
+	// Prompt is true if the prompt itself was blocked
+	Prompt     bool
+	Reason     string
+	Categories []string
+}
+
+func (e *SafetyBlockError) Error() string {
+	subject := "response"
+	if e.Prompt {
+		subject = "prompt"
+	}
+	msg := fmt.Sprintf("%s blocked by safety filters (%s)", subject, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += ": " + strings.Join(e.Categories, ", ")
+	}
+	return msg
+}
+
+// geminiBlockedFinishReasons are the finish reasons that mean the candidate
+// was withheld rather than completed
+var geminiBlockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// SupportsJSONMode reports that replies can be constrained to JSON, with response schemas
+func (p *GeminiProvider) SupportsJSONMode() bool {
+	return true
+}
+
+func (p *GeminiProvider) Call(req Request) (Response, error) {
+	resp, err := p.send(req, "generateContent")
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var response GeminiResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	content, err := response.text()
+	if err != nil {
+		return Response{}, err
+	}
+	if content == "" {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: content, Usage: response.usage()}, nil
+}
+
+// Stream requests server-sent events from streamGenerateContent and forwards text deltas to onDelta
+func (p *GeminiProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	resp, err := p.send(req, "streamGenerateContent")
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage Usage
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error.Message != "" {
+			return fmt.Errorf("API error: %s", chunk.Error.Message)
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.usage()
+		}
+
+		delta, err := chunk.text()
+		if delta != "" {
+			content.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return Response{Content: content.String(), Usage: usage}, err
+	}
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: content.String(), Usage: usage}, nil
+}
+
+// text returns the text of the first candidate, or a SafetyBlockError if
+// the prompt or the candidate was blocked
+func (r *GeminiResponse) text() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", &SafetyBlockError{
+			Prompt:     true,
+			Reason:     r.PromptFeedback.BlockReason,
+			Categories: blockedCategories(r.PromptFeedback.SafetyRatings),
+		}
+	}
+	if len(r.Candidates) == 0 {
+		return "", nil
+	}
+
+	candidate := r.Candidates[0]
+	var content strings.Builder
+	for _, part := range candidate.Content.Parts {
+		content.WriteString(part.Text)
+	}
+
+	if geminiBlockedFinishReasons[candidate.FinishReason] {
+		return content.String(), &SafetyBlockError{
+			Reason:     candidate.FinishReason,
+			Categories: blockedCategories(candidate.SafetyRatings),
+		}
+	}
+	return content.String(), nil
+}
+
+// usage returns the token counts of the usage metadata, if present
+func (r *GeminiResponse) usage() Usage {
+	if r.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+	}
+}
+
+func blockedCategories(ratings []GeminiSafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked || rating.Probability == "HIGH" {
+			categories = append(categories, rating.Category)
+		}
+	}
+	return categories
+}
+
+// newRequest maps chat messages to Gemini contents: "assistant" becomes
+// "model" and system messages move into systemInstruction
+func (p *GeminiProvider) newRequest(req Request) GeminiRequest {
+	var reqBody GeminiRequest
+	var system []GeminiPart
+
+	for _, message := range req.Messages {
+		switch message.Role {
+		case "system":
+			if message.Content != "" {
+				system = append(system, GeminiPart{Text: message.Content})
+			}
+		case "assistant":
+			reqBody.Contents = append(reqBody.Contents, GeminiContent{Role: "model", Parts: []GeminiPart{{Text: message.Content}}})
+		default:
+			reqBody.Contents = append(reqBody.Contents, GeminiContent{Role: "user", Parts: []GeminiPart{{Text: message.Content}}})
+		}
+	}
+
+	if len(system) > 0 {
+		reqBody.SystemInstruction = &GeminiContent{Parts: system}
+	}
+
+	if params := req.Params; len(params.set()) > 0 {
+		reqBody.GenerationConfig = &GeminiGenerationConfig{
+			Temperature:      params.Temperature,
+			TopP:             params.TopP,
+			MaxOutputTokens:  params.MaxTokens,
+			StopSequences:    params.Stop,
+			Seed:             params.Seed,
+			PresencePenalty:  params.PresencePenalty,
+			FrequencyPenalty: params.FrequencyPenalty,
+		}
+		if params.JSON() {
+			reqBody.GenerationConfig.ResponseMimeType = "application/json"
+			reqBody.GenerationConfig.ResponseJSONSchema = params.Schema
+		}
+	}
+	return reqBody
+}
+
+// send posts the request to the given model method and returns the response if the API reported success
+func (p *GeminiProvider) send(req Request, method string) (*http.Response, error) {
+	if err := checkNoImages(p.Name, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := checkNoTools(p.Name, req); err != nil {
+		return nil, err
+	}
+
+	base := GEMINI_API
+	if req.BaseURL != "" {
+		base = strings.TrimRight(req.BaseURL, "/")
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:%s", base, url.PathEscape(req.Model), method)
+	params := map[string]string{}
+	for key, value := range req.QueryParams {
+		params[key] = value
+	}
+	if method == "streamGenerateContent" {
+		params["alt"] = "sse"
+	}
+	endpoint, err := addQueryParams(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := newJSONRequestWithAuth(endpoint, p.newRequest(req), req, "x-goog-api-key", "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doRequest(httpReq, req, method == "streamGenerateContent")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+
+		var errorResp GeminiResponse
+		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+			return nil, statusError(resp.StatusCode, "API error: %s (%s)", errorResp.Error.Message, errorResp.Error.Status)
+		}
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
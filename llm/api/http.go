@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const chatCompletionsPath = "/chat/completions"
+
+// endpointURL returns the URL for the request, using defaultURL unless the
+// request overrides the base URL, in which case path is appended to it
+func endpointURL(req Request, defaultURL string, path string) (string, error) {
+	endpoint := defaultURL
+	if req.BaseURL != "" {
+		endpoint = strings.TrimRight(req.BaseURL, "/")
+		if !strings.HasSuffix(endpoint, path) {
+			endpoint += path
+		}
+	}
+
+	return addQueryParams(endpoint, req.QueryParams)
+}
+
+// addQueryParams appends params to the query string of endpoint
+func addQueryParams(endpoint string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %v", err)
+	}
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// newJSONRequest builds a POST request carrying body as JSON, with bearer
+// authentication when an API key is set and the request's extra headers
+func newJSONRequest(endpoint string, body interface{}, req Request) (*http.Request, error) {
+	return newJSONRequestWithAuth(endpoint, body, req, "Authorization", "Bearer ")
+}
+
+// newJSONRequestWithAuth is like newJSONRequest but sends the API key in
+// authHeader, prefixed with authPrefix
+func newJSONRequestWithAuth(endpoint string, body interface{}, req Request, authHeader, authPrefix string) (*http.Request, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	if req.APIKey != "" {
+		httpReq.Header.Set(authHeader, authPrefix+req.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	return httpReq, nil
+}
+
+// APIError is returned when a provider answers with a non-success status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// statusError builds an APIError for the given status with a formatted message
+func statusError(statusCode int, format string, a ...interface{}) error {
+	return &APIError{StatusCode: statusCode, Message: fmt.Sprintf(format, a...)}
+}
+
+// IsRetryable reports whether a failed call may succeed on another attempt
+// or with another model: network failures, timeouts, rate limits and
+// server errors are retryable, while bad requests or keys are not
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.StatusCode)
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
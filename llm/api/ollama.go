@@ -0,0 +1,272 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const OLLAMA_API = "http://localhost:11434"
+
+type OllamaProvider struct {
+	BaseProvider
+}
+
+type OllamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	// Format is "json" or a JSON Schema
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options *OllamaOptions  `json:"options,omitempty"`
+}
+
+type OllamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+// OllamaResponse is a complete response, or one NDJSON line of a streamed one
+type OllamaResponse struct {
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+	// Token counts, reported on the final message
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (r *OllamaResponse) usage() Usage {
+	return Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+}
+
+type OllamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// SupportsJSONMode reports that replies can be constrained to JSON, with format schemas
+func (p *OllamaProvider) SupportsJSONMode() bool {
+	return true
+}
+
+func (p *OllamaProvider) Call(req Request) (Response, error) {
+	resp, err := p.send(req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var response OllamaResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if response.Error != "" {
+		return Response{}, fmt.Errorf("API error: %s", response.Error)
+	}
+
+	if response.Message.Content == "" {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: response.Message.Content, Usage: response.usage()}, nil
+}
+
+// Stream reads Ollama's newline-delimited JSON stream and forwards content deltas to onDelta
+func (p *OllamaProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	resp, err := p.send(req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk OllamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return Response{Content: content.String(), Usage: usage}, fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return Response{Content: content.String(), Usage: usage}, fmt.Errorf("API error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			usage = chunk.usage()
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{Content: content.String(), Usage: usage}, fmt.Errorf("failed to read stream: %v", err)
+	}
+
+	if content.Len() == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: content.String(), Usage: usage}, nil
+}
+
+// ListModels returns the names of the models pulled on the Ollama host
+func (p *OllamaProvider) ListModels(req Request) ([]string, error) {
+	endpoint, err := addQueryParams(p.baseURL(req)+"/api/tags", req.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := doRequest(httpReq, req, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Ollama at %s: %w", p.baseURL(req), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tags OllamaTagsResponse
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	names := make([]string, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		names = append(names, model.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HasModel reports whether model is among the installed names, treating
+// an untagged name as ":latest" the way Ollama does
+func (p *OllamaProvider) HasModel(installed []string, model string) bool {
+	for _, name := range installed {
+		if name == model || (!strings.Contains(model, ":") && name == model+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *OllamaProvider) baseURL(req Request) string {
+	if req.BaseURL != "" {
+		return strings.TrimRight(req.BaseURL, "/")
+	}
+	return OLLAMA_API
+}
+
+// send posts the request and returns the response if the API reported success
+func (p *OllamaProvider) send(req Request, stream bool) (*http.Response, error) {
+	if err := checkNoImages(p.Name, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := checkNoTools(p.Name, req); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := addQueryParams(p.baseURL(req)+"/api/chat", req.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := OllamaRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Stream:   stream,
+	}
+	if params := req.Params; len(params.set()) > 0 {
+		reqBody.Options = &OllamaOptions{
+			Temperature:      params.Temperature,
+			TopP:             params.TopP,
+			NumPredict:       params.MaxTokens,
+			Stop:             params.Stop,
+			Seed:             params.Seed,
+			PresencePenalty:  params.PresencePenalty,
+			FrequencyPenalty: params.FrequencyPenalty,
+		}
+		if params.JSON() {
+			reqBody.Format = json.RawMessage(`"json"`)
+			if len(params.Schema) > 0 {
+				reqBody.Format = params.Schema
+			}
+		}
+	}
+	httpReq, err := newJSONRequest(endpoint, reqBody, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doRequest(httpReq, req, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Ollama at %s: %w", p.baseURL(req), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+
+		var errorResp OllamaResponse
+		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error != "" {
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, p.modelNotFound(req, errorResp.Error)
+			}
+			return nil, statusError(resp.StatusCode, "API error: %s", errorResp.Error)
+		}
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// modelNotFound explains a missing model by listing what is installed
+func (p *OllamaProvider) modelNotFound(req Request, apiError string) error {
+	installed, err := p.ListModels(req)
+	if err != nil || len(installed) == 0 {
+		return fmt.Errorf("API error: %s (pull it with 'ollama pull %s')", apiError, req.Model)
+	}
+	return fmt.Errorf("API error: %s; installed models: %s", apiError, strings.Join(installed, ", "))
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,68 +14,146 @@ type OpenAIProvider struct {
 }
 
 type OpenAIRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model            string                `json:"model"`
+	Messages         []chatMessage         `json:"messages"`
+	Stream           bool                  `json:"stream,omitempty"`
+	Temperature      *float64              `json:"temperature,omitempty"`
+	TopP             *float64              `json:"top_p,omitempty"`
+	MaxTokens        *int                  `json:"max_tokens,omitempty"`
+	Stop             []string              `json:"stop,omitempty"`
+	Seed             *int                  `json:"seed,omitempty"`
+	PresencePenalty  *float64              `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64              `json:"frequency_penalty,omitempty"`
+	ResponseFormat   *openAIResponseFormat `json:"response_format,omitempty"`
+	Tools            []openAITool          `json:"tools,omitempty"`
+	StreamOptions    *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions asks for a final chunk carrying the usage block
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type OpenAIResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *ChatCompletionUsage `json:"usage"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
-func (p *OpenAIProvider) Call(model string, messages []Message, apiKey string) (string, error) {
-	reqBody := OpenAIRequest{
-		Model:    model,
-		Messages: messages,
-	}
+// SupportsJSONMode reports that replies can be constrained to JSON, with structured outputs for schemas
+func (p *OpenAIProvider) SupportsJSONMode() bool {
+	return true
+}
 
-	jsonData, err := json.Marshal(reqBody)
+func (p *OpenAIProvider) Call(req Request) (Response, error) {
+	resp, err := p.send(req, p.newRequest(req, false))
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return Response{}, err
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequest("POST", OPENAI_API, bytes.NewBuffer(jsonData))
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	var response OpenAIResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	if len(response.Choices) == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+
+	return Response{
+		Content:   response.Choices[0].Message.Content,
+		Usage:     response.Usage.usage(),
+		ToolCalls: toolCalls(response.Choices[0].Message.ToolCalls),
+	}, nil
+}
+
+// Stream sends the request with "stream": true and forwards content deltas to onDelta
+func (p *OpenAIProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	resp, err := p.send(req, p.newRequest(req, true))
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return Response{}, err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return readChatCompletionStream(resp.Body, onDelta)
+}
+
+// newRequest builds the request body; every generation parameter is supported.
+// The usage chunk and structured outputs are requested unless disabled.
+func (p *OpenAIProvider) newRequest(req Request, stream bool) OpenAIRequest {
+	var streamOptions *openAIStreamOptions
+	if stream && enabled(req.StreamUsage, true) {
+		streamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	return OpenAIRequest{
+		Model:            req.Model,
+		Messages:         chatMessages(req.Messages, false),
+		Stream:           stream,
+		Temperature:      req.Params.Temperature,
+		TopP:             req.Params.TopP,
+		MaxTokens:        req.Params.MaxTokens,
+		Stop:             req.Params.Stop,
+		Seed:             req.Params.Seed,
+		PresencePenalty:  req.Params.PresencePenalty,
+		FrequencyPenalty: req.Params.FrequencyPenalty,
+		ResponseFormat:   req.Params.openAIResponseFormat(enabled(req.StructuredOutputs, true)),
+		Tools:            openAITools(req.Tools),
+		StreamOptions:    streamOptions,
+	}
+}
+
+// send posts the request and returns the response if the API reported success
+func (p *OpenAIProvider) send(req Request, reqBody OpenAIRequest) (*http.Response, error) {
+	endpoint, err := endpointURL(req, OPENAI_API, chatCompletionsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := newJSONRequest(endpoint, reqBody, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doRequest(httpReq, req, reqBody.Stream)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+
 		var errorResp OpenAIResponse
 		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
-			return "", fmt.Errorf("API error: %s", errorResp.Error.Message)
+			return nil, statusError(resp.StatusCode, "API error: %s", errorResp.Error.Message)
 		}
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, statusError(resp.StatusCode, "API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var response OpenAIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
-	}
+	return resp, nil
+}
 
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no response content")
+// enabled returns the value of an optional capability, or def if it is unset
+func enabled(capability *bool, def bool) bool {
+	if capability == nil {
+		return def
 	}
-
-	return response.Choices[0].Message.Content, nil
-} 
\ No newline at end of file
+	return *capability
+}
@@ -0,0 +1,46 @@
+package api
+
+import "fmt"
+
+// OpenAICompatibleProvider talks to any server implementing the OpenAI chat
+// completions API (Azure OpenAI, vLLM, Ollama, LM Studio, OpenRouter, ...).
+// Unlike OpenAIProvider it has no default endpoint, so BaseURL is required,
+// and extensions many servers reject, the usage chunk of streams and
+// structured outputs, are only used if the model config enables them.
+type OpenAICompatibleProvider struct {
+	OpenAIProvider
+}
+
+func (p *OpenAICompatibleProvider) Call(req Request) (Response, error) {
+	if err := p.validate(req); err != nil {
+		return Response{}, err
+	}
+	return p.OpenAIProvider.Call(p.defaults(req))
+}
+
+// Stream sends the request with "stream": true and forwards content deltas to onDelta
+func (p *OpenAICompatibleProvider) Stream(req Request, onDelta StreamHandler) (Response, error) {
+	if err := p.validate(req); err != nil {
+		return Response{}, err
+	}
+	return p.OpenAIProvider.Stream(p.defaults(req), onDelta)
+}
+
+func (p *OpenAICompatibleProvider) validate(req Request) error {
+	if req.BaseURL == "" {
+		return fmt.Errorf("%s provider requires BaseURL in the model config", p.Name)
+	}
+	return nil
+}
+
+// defaults disables the extensions the model config does not enable
+func (p *OpenAICompatibleProvider) defaults(req Request) Request {
+	disabled := false
+	if req.StreamUsage == nil {
+		req.StreamUsage = &disabled
+	}
+	if req.StructuredOutputs == nil {
+		req.StructuredOutputs = &disabled
+	}
+	return req
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSON       = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// GenerationParams are optional sampling settings. Unset fields are left to
+// the provider's defaults; each provider maps them to its own wire format.
+type GenerationParams struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	MaxTokens        *int     `json:"maxTokens,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	// ResponseFormat is "text", "json_object" or "json_schema"
+	ResponseFormat string `json:"responseFormat,omitempty"`
+	// Schema is the JSON Schema of the reply when ResponseFormat is "json_schema"
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// Merge returns p with every field that is set in override replaced
+func (p GenerationParams) Merge(override GenerationParams) GenerationParams {
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		p.MaxTokens = override.MaxTokens
+	}
+	if override.Stop != nil {
+		p.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		p.Seed = override.Seed
+	}
+	if override.PresencePenalty != nil {
+		p.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		p.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.ResponseFormat != "" {
+		p.ResponseFormat = override.ResponseFormat
+		p.Schema = override.Schema
+	}
+	return p
+}
+
+// Validate checks the values are in range
+func (p GenerationParams) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1, got %g", *p.TopP)
+	}
+	if p.MaxTokens != nil && *p.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive, got %d", *p.MaxTokens)
+	}
+	if p.PresencePenalty != nil && (*p.PresencePenalty < -2 || *p.PresencePenalty > 2) {
+		return fmt.Errorf("presence_penalty must be between -2 and 2, got %g", *p.PresencePenalty)
+	}
+	if p.FrequencyPenalty != nil && (*p.FrequencyPenalty < -2 || *p.FrequencyPenalty > 2) {
+		return fmt.Errorf("frequency_penalty must be between -2 and 2, got %g", *p.FrequencyPenalty)
+	}
+	switch p.ResponseFormat {
+	case "", ResponseFormatText, ResponseFormatJSON:
+		if len(p.Schema) > 0 {
+			return fmt.Errorf("a schema requires response_format %q", ResponseFormatJSONSchema)
+		}
+	case ResponseFormatJSONSchema:
+		if len(p.Schema) == 0 {
+			return fmt.Errorf("response_format %q requires a schema", ResponseFormatJSONSchema)
+		}
+	default:
+		return fmt.Errorf("response_format must be %q, %q or %q, got %q", ResponseFormatText, ResponseFormatJSON, ResponseFormatJSONSchema, p.ResponseFormat)
+	}
+	return nil
+}
+
+// JSON reports whether a JSON response was requested, with or without a schema
+func (p GenerationParams) JSON() bool {
+	return p.ResponseFormat == ResponseFormatJSON || p.ResponseFormat == ResponseFormatJSONSchema
+}
+
+// set returns the names of the parameters that have a value
+func (p GenerationParams) set() []string {
+	var names []string
+	if p.Temperature != nil {
+		names = append(names, "temperature")
+	}
+	if p.TopP != nil {
+		names = append(names, "top_p")
+	}
+	if p.MaxTokens != nil {
+		names = append(names, "max_tokens")
+	}
+	if len(p.Stop) > 0 {
+		names = append(names, "stop")
+	}
+	if p.Seed != nil {
+		names = append(names, "seed")
+	}
+	if p.PresencePenalty != nil {
+		names = append(names, "presence_penalty")
+	}
+	if p.FrequencyPenalty != nil {
+		names = append(names, "frequency_penalty")
+	}
+	if p.ResponseFormat != "" && p.ResponseFormat != ResponseFormatText {
+		names = append(names, "response_format")
+	}
+	return names
+}
+
+// checkSupported returns an error naming every set parameter that is not in supported
+func (p GenerationParams) checkSupported(provider string, supported ...string) error {
+	allowed := make(map[string]bool, len(supported))
+	for _, name := range supported {
+		allowed[name] = true
+	}
+
+	var unsupported []string
+	for _, name := range p.set() {
+		if !allowed[name] {
+			unsupported = append(unsupported, name)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s provider does not support: %s", provider, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// openAIResponseFormat is the "response_format" object of the chat completions API
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// openAIJSONSchema names the schema of a structured output
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// openAIResponseFormat returns the response format of the request. Without
+// structured output support a schema falls back to plain JSON mode.
+func (p GenerationParams) openAIResponseFormat(structured bool) *openAIResponseFormat {
+	switch {
+	case p.ResponseFormat == "":
+		return nil
+	case p.ResponseFormat == ResponseFormatJSONSchema && structured:
+		return &openAIResponseFormat{Type: p.ResponseFormat, JSONSchema: &openAIJSONSchema{Name: "response", Schema: p.Schema}}
+	case p.ResponseFormat == ResponseFormatJSONSchema:
+		return &openAIResponseFormat{Type: ResponseFormatJSON}
+	}
+	return &openAIResponseFormat{Type: p.ResponseFormat}
+}
+
+// JSONModeProvider is implemented by providers that can constrain replies to
+// JSON, and to a schema where the API supports it, via ResponseFormat
+type JSONModeProvider interface {
+	LLMProvider
+	SupportsJSONMode() bool
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a whole non-streaming request, or the wait for
+	// the first response headers of a streaming one
+	DefaultTimeout = 120 * time.Second
+	// DefaultMaxAttempts is how often a request is tried before giving up
+	DefaultMaxAttempts = 3
+	// maxRetryDelay caps both the exponential backoff and Retry-After
+	maxRetryDelay = 60 * time.Second
+)
+
+// RetryBaseDelay is the backoff before the second attempt; each further
+// attempt doubles it. It is a variable so tests can shorten it.
+var RetryBaseDelay = 500 * time.Millisecond
+
+// retryableStatus reports whether a response status is worth retrying:
+// rate limits, server errors and Anthropic's 529 "overloaded"
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529:
+		return true
+	}
+	return false
+}
+
+// doRequest sends httpReq with the timeout and retry policy of req.
+// Network errors and retryable statuses are retried with exponential
+// backoff and jitter, honoring Retry-After. Once attempts are exhausted the
+// last response is returned so the caller can report the API's error body.
+func doRequest(httpReq *http.Request, req Request, stream bool) (*http.Response, error) {
+	client := newHTTPClient(req, stream)
+
+	attempts := req.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && httpReq.GetBody != nil {
+			body, err := httpReq.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %v", err)
+			}
+			httpReq.Body = body
+		}
+
+		resp, err := client.Do(httpReq)
+		if attempt >= attempts {
+			return resp, err
+		}
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := backoff(attempt)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = retryAfter
+			}
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(delay)
+	}
+}
+
+// newHTTPClient returns a client enforcing the request timeout. Streams may
+// legitimately run for minutes, so for them only the wait for the response
+// headers is bounded.
+func newHTTPClient(req Request, stream bool) *http.Client {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	if !stream {
+		return &http.Client{Timeout: timeout}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
+
+// backoff returns the delay after the given failed attempt: exponential
+// growth from RetryBaseDelay, randomized between half and the full value
+func backoff(attempt int) time.Duration {
+	delay := RetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay, true
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChatCompletionChunk is a single server-sent event of a streamed chat
+// completion, shared by the OpenAI and ChatGLM formats
+type ChatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *ChatCompletionUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ChatCompletionUsage is the "usage" block of the OpenAI and ChatGLM formats
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *ChatCompletionUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// readSSE reads server-sent events from body and passes the payload of
+// every "data:" line to onData until the stream ends or "[DONE]" is sent
+func readSSE(body io.Reader, onData func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // Skip comments, event names and keep-alive blank lines
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			return nil
+		}
+
+		if err := onData(data); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %v", err)
+	}
+	return nil
+}
+
+// readChatCompletionStream collects the content deltas of a streamed chat
+// completion, forwarding each one to onDelta, and assembles its tool calls
+func readChatCompletionStream(body io.Reader, onDelta StreamHandler) (Response, error) {
+	var content strings.Builder
+	var usage Usage
+	calls := toolCallStream{}
+
+	err := readSSE(body, func(data string) error {
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error.Message != "" {
+			return fmt.Errorf("API error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+
+		for _, choice := range chunk.Choices {
+			calls.add(choice.Delta.ToolCalls)
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Response{Content: content.String(), Usage: usage}, err
+	}
+
+	if content.Len() == 0 && len(calls) == 0 {
+		return Response{}, fmt.Errorf("no response content")
+	}
+	return Response{Content: content.String(), Usage: usage, ToolCalls: calls.calls()}, nil
+}
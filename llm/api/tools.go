@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Tool is a function the model may call, described to it by name, purpose
+// and a JSON Schema of its arguments
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a request by the model to run a tool
+type ToolCall struct {
+	ID   string
+	Name string
+	// Arguments is the JSON object of arguments written by the model
+	Arguments string
+}
+
+// openAITool is a tool in the chat completions format
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// openAIToolCall is a tool call in the chat completions format. Index
+// identifies the call that a streamed delta continues.
+type openAIToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAITools converts tools to the chat completions format
+func openAITools(tools []Tool) []openAITool {
+	var converted []openAITool
+	for _, tool := range tools {
+		converted = append(converted, openAITool{
+			Type:     "function",
+			Function: openAIFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+	return converted
+}
+
+// openAIToolCalls converts tool calls to the chat completions format
+func openAIToolCalls(calls []ToolCall) []openAIToolCall {
+	var converted []openAIToolCall
+	for _, call := range calls {
+		c := openAIToolCall{ID: call.ID, Type: "function"}
+		c.Function.Name = call.Name
+		c.Function.Arguments = call.Arguments
+		converted = append(converted, c)
+	}
+	return converted
+}
+
+// toolCalls converts tool calls from the chat completions format
+func toolCalls(calls []openAIToolCall) []ToolCall {
+	var converted []ToolCall
+	for _, call := range calls {
+		converted = append(converted, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return converted
+}
+
+// toolCallStream assembles tool calls whose id, name and arguments arrive
+// in pieces over a streamed chat completion
+type toolCallStream map[int]*ToolCall
+
+func (s toolCallStream) add(deltas []openAIToolCall) {
+	for i, delta := range deltas {
+		index := i
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+		call, ok := s[index]
+		if !ok {
+			call = &ToolCall{}
+			s[index] = call
+		}
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		call.Name += delta.Function.Name
+		call.Arguments += delta.Function.Arguments
+	}
+}
+
+// calls returns the assembled tool calls in order
+func (s toolCallStream) calls() []ToolCall {
+	indexes := make([]int, 0, len(s))
+	for index := range s {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var calls []ToolCall
+	for _, index := range indexes {
+		calls = append(calls, *s[index])
+	}
+	return calls
+}
+
+// checkNoTools returns an error if the request uses tools the provider cannot call
+func checkNoTools(provider string, req Request) error {
+	if len(req.Tools) > 0 {
+		return fmt.Errorf("%s provider does not support tool calling", provider)
+	}
+	for _, message := range req.Messages {
+		if len(message.ToolCalls) > 0 || message.Role == "tool" {
+			return fmt.Errorf("%s provider does not support tool calling", provider)
+		}
+	}
+	return nil
+}
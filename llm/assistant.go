@@ -4,11 +4,52 @@ import (
 	"fmt"
 	"llm_cli/config"
 	"llm_cli/llm/api"
+	"llm_cli/llm/mcp"
+	"llm_cli/llm/tools"
 	"llm_cli/utils"
 )
 
+// AssistantOptions holds per-call overrides for an assistant call
+type AssistantOptions struct {
+	// Model overrides the model configured for the assistant
+	Model string
+	// Session selects the conversation thread; empty uses the current session
+	Session string
+	// Params override the generation parameters of the assistant and its model
+	Params api.GenerationParams
+	// OnDelta receives streamed content; nil disables streaming
+	OnDelta api.StreamHandler
+	// Images are attached to the input; history keeps their paths or URLs
+	Images []api.Image
+	// JSON asks for a validated JSON reply; it disables streaming
+	JSON *JSONOutput
+	// Confirm approves tool calls with side effects; nil declines them
+	Confirm ConfirmFunc
+	// OnToolCalls is called before the tool calls of a streamed reply run,
+	// e.g. to clear the text streamed so far from the terminal
+	OnToolCalls func()
+	// Vars are used to render the assistant's prompt if it is a template;
+	// the files it may include are those of the config
+	Vars PromptVars
+	// MCPServers keeps the MCP servers of the assistant running across
+	// calls; nil starts them for this call only
+	MCPServers *mcp.Servers
+}
+
 // AssistantCall sends a request using a configured assistant
 func AssistantCall(assistantName string, input string) (string, error) {
+	return AssistantCallWithOptions(assistantName, input, AssistantOptions{})
+}
+
+// AssistantStreamCall is the streaming variant of AssistantCall. The
+// conversation is only stored in history once the stream has completed.
+func AssistantStreamCall(assistantName string, input string, onDelta api.StreamHandler) (string, error) {
+	return AssistantCallWithOptions(assistantName, input, AssistantOptions{OnDelta: onDelta})
+}
+
+// AssistantCallWithOptions sends a request using a configured assistant and
+// the given per-call overrides
+func AssistantCallWithOptions(assistantName string, input string, opts AssistantOptions) (string, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return "", fmt.Errorf("failed to get config: %v", err)
@@ -20,6 +61,33 @@ func AssistantCall(assistantName string, input string) (string, error) {
 		return "", fmt.Errorf("assistant '%s' not found in config", assistantName)
 	}
 
+	available, err := tools.ForAssistant(assistant, cfg.Tools)
+	if err != nil {
+		return "", fmt.Errorf("assistant '%s': %v", assistantName, err)
+	}
+	if len(assistant.MCPServers) > 0 {
+		servers := opts.MCPServers
+		if servers == nil {
+			servers = mcp.NewServers(cfg.MCPServers)
+			defer servers.Close()
+		}
+		serverTools, err := servers.Tools(assistant.MCPServers)
+		if err != nil {
+			return "", fmt.Errorf("assistant '%s': %v", assistantName, err)
+		}
+		for name, tool := range serverTools {
+			if _, exists := available[name]; exists {
+				return "", fmt.Errorf("assistant '%s': MCP tool '%s' has the name of another tool", assistantName, name)
+			}
+			available[name] = tool
+		}
+	}
+
+	modelName := assistant.Model
+	if opts.Model != "" {
+		modelName = opts.Model
+	}
+
 	// Initialize history
 	history, err := utils.NewHistory()
 	if err != nil {
@@ -27,54 +95,155 @@ func AssistantCall(assistantName string, input string) (string, error) {
 	}
 	defer history.Close()
 
-	// Get recent chat context
-	records, err := history.Fetch(assistantName, assistant.ChatContextWindow*2) // *2 because each turn has 2 messages
+	session := opts.Session
+	if session == "" {
+		session, err = history.CurrentSession(assistantName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get session: %v", err)
+		}
+	}
+
+	chain, models, err := modelChain(modelName)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch history: %v", err)
+		return "", err
 	}
 
-	// Build messages array starting with system prompt
-	messages := []api.Message{
-		{
-			Role:    "system",
-			Content: assistant.Prompt,
-		},
+	// Get recent chat context from the current session only. Models with
+	// contextTokens get as much as fits, others the last chatContextWindow
+	// turns, so load enough history for each model that may answer.
+	window := assistant.ChatContextWindow * 2 // *2 because each turn has 2 messages
+	limit := window
+	for _, name := range chain {
+		if models[name].ContextTokens > 0 {
+			limit = maxContextRecords
+		}
+	}
+	// Messages already condensed into the session summary are not sent again
+	var summary utils.Summary
+	if assistant.Summarize != nil {
+		summary, err = history.GetSummary(assistantName, session)
+		if err != nil {
+			return "", err
+		}
+	}
+	records, err := history.FetchSessionAfter(assistantName, session, summary.LastID, limit)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch history: %v", err)
 	}
 
-	// Add context messages in chronological order
-	// No need to reverse since Fetch now returns them in chronological order
+	// Build messages starting with the system prompt and ending with the
+	// current input; the history is trimmed for each model that is tried
+	prompt := assistant.Prompt
+	if assistant.PromptTemplate {
+		vars := opts.Vars
+		vars.Files = cfg.PromptFiles
+		prompt, err = RenderPrompt(fmt.Sprintf("prompt of assistant '%s'", assistantName), prompt, vars)
+		if err != nil {
+			return "", err
+		}
+	}
+	messages := []api.Message{{Role: "system", Content: prompt}}
+	if summary.Content != "" {
+		messages = append(messages, api.Message{Role: "system", Content: summaryPrefix + summary.Content})
+	}
+	params := assistant.GenerationParams.Merge(opts.Params)
+	fitter := contextFitter{leading: len(messages), history: len(records), window: window, params: params}
 	for _, record := range records {
 		messages = append(messages, api.Message{
 			Role:    record.Role,
 			Content: record.Content,
 		})
 	}
+	messages = append(messages, api.Message{Role: "user", Content: input, Images: opts.Images})
 
-	// Add current user input
-	messages = append(messages, api.Message{
-		Role:    "user",
-		Content: input,
-	})
+	// The assistant budget is checked against what the first model would get
+	fitted, _, err := fitter.trim(chain[0], models[chain[0]], messages)
+	if err != nil {
+		return "", err
+	}
+	if err := checkBudget("assistant", assistantName, assistant.Budget, estimateUsage(fitted), models[chain[0]].Price); err != nil {
+		return "", err
+	}
 
 	// Call the model
-	response, err := Call(assistant.Model, messages)
+	runner := &toolRunner{
+		tools:     available,
+		confirm:   opts.Confirm,
+		history:   history,
+		assistant: assistantName,
+		session:   session,
+		before:    opts.OnToolCalls,
+	}
+	callOpts := CallOptions{Params: params, OnDelta: opts.OnDelta, JSON: opts.JSON, Tools: runner.definitions(), fit: fitter.fit}
+	if opts.JSON != nil {
+		// Invalid replies are retried, so nothing is streamed
+		callOpts.OnDelta = nil
+	}
+	// Once a model has answered, later tool rounds and retries go to it
+	// rather than starting over at the primary model
+	answering := modelName
+	response, answeredBy, err := callWithOutput(messages, opts.JSON, func(messages []api.Message) (api.Response, string, error) {
+		return callWithTools(messages, runner, func(messages []api.Message) (api.Response, string, error) {
+			response, answeredBy, err := callWithFallback(answering, messages, callOpts)
+			if err == nil {
+				answering = answeredBy
+				recordUsage(history, assistantName, answeredBy, response.Usage)
+			}
+			return response, answeredBy, err
+		})
+	})
 	if err != nil {
-		return "", fmt.Errorf("model call failed: %v", err)
+		return "", fmt.Errorf("model call failed: %w", err)
 	}
 
 	// Store the conversation in history
-	if err := history.Push(assistantName, "user", input); err != nil {
+	var images []string
+	for _, image := range opts.Images {
+		images = append(images, image.Source)
+	}
+	if err := history.Add(utils.Record{
+		Assistant: assistantName,
+		Session:   session,
+		Role:      "user",
+		Content:   input,
+		Images:    images,
+	}); err != nil {
 		return "", fmt.Errorf("failed to store user message: %v", err)
 	}
-	if err := history.Push(assistantName, "assistant", response); err != nil {
+	if err := history.Add(utils.Record{
+		Assistant: assistantName,
+		Session:   session,
+		Role:      "assistant",
+		Content:   response.Content,
+		Model:     answeredBy,
+	}); err != nil {
 		return "", fmt.Errorf("failed to store assistant response: %v", err)
 	}
 
-	return response, nil
+	if assistant.Summarize != nil {
+		summarize(history, assistantName, session, assistant, modelName, summary)
+	}
+
+	return response.Content, nil
 }
 
 // SimpleAssistantCall uses the default assistant if none specified
 func SimpleAssistantCall(input string) (string, error) {
+	return SimpleAssistantStreamCall(input, nil)
+}
+
+// SimpleAssistantStreamCall is the streaming variant of SimpleAssistantCall
+func SimpleAssistantStreamCall(input string, onDelta api.StreamHandler) (string, error) {
+	defaultAssistant, err := DefaultAssistant()
+	if err != nil {
+		return "", err
+	}
+
+	return AssistantStreamCall(defaultAssistant, input, onDelta)
+}
+
+// DefaultAssistant returns the name of the assistant used when none is specified
+func DefaultAssistant() (string, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return "", fmt.Errorf("failed to get config: %v", err)
@@ -91,5 +260,5 @@ func SimpleAssistantCall(input string) (string, error) {
 		return "", fmt.Errorf("no assistants configured")
 	}
 
-	return AssistantCall(defaultAssistant, input)
-} 
\ No newline at end of file
+	return defaultAssistant, nil
+}
@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+	"os"
+	"time"
+)
+
+// budgetWarnRatio is the share of a budget after which the remainder is reported
+const budgetWarnRatio = 0.8
+
+// ErrBudgetExceeded is wrapped by the errors of calls refused by a budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// checkBudget compares the usage recorded for a model or assistant (kind is
+// "model" or "assistant") in the current day and month against its budget,
+// including an estimate of the call about to be made. Over budget it returns
+// an error wrapping ErrBudgetExceeded, or only prints a warning if the
+// budget's policy is "warn".
+func checkBudget(kind, name string, budget *config.Budget, estimate api.Usage, price *config.ModelPrice) error {
+	if budget == nil || (budget.Daily == nil && budget.Monthly == nil) {
+		return nil
+	}
+
+	switch budget.Policy {
+	case "", config.BudgetPolicyRefuse, config.BudgetPolicyWarn:
+	default:
+		return fmt.Errorf("invalid budget policy %q for %s '%s', use %q or %q", budget.Policy, kind, name, config.BudgetPolicyRefuse, config.BudgetPolicyWarn)
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		return fmt.Errorf("failed to initialize history: %v", err)
+	}
+	defer history.Close()
+
+	now := time.Now()
+	periods := []struct {
+		name  string
+		limit *config.BudgetLimit
+		since time.Time
+	}{
+		{"daily", budget.Daily, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())},
+		{"monthly", budget.Monthly, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())},
+	}
+
+	for _, period := range periods {
+		if period.limit == nil {
+			continue
+		}
+
+		spent, err := history.UsageTotal(period.since, kind, name)
+		if err != nil {
+			return err
+		}
+		tokens := spent.PromptTokens + spent.CompletionTokens
+		cost := spent.Cost
+
+		var exceeded, remaining string
+		if limit := period.limit.Tokens; limit > 0 {
+			if tokens+estimate.TotalTokens() > limit {
+				exceeded = fmt.Sprintf("%d of %d tokens used", tokens, limit)
+			} else if float64(tokens) >= budgetWarnRatio*float64(limit) {
+				remaining = fmt.Sprintf("%d tokens", limit-tokens)
+			}
+		}
+		if limit := period.limit.Cost; limit > 0 && exceeded == "" {
+			if cost+price.Cost(estimate) > limit {
+				exceeded = fmt.Sprintf("$%.4f of $%.4f used", cost, limit)
+			} else if cost >= budgetWarnRatio*limit {
+				remaining = fmt.Sprintf("$%.4f", limit-cost)
+			}
+		}
+
+		if exceeded != "" {
+			if budget.Policy == config.BudgetPolicyWarn {
+				fmt.Fprintf(os.Stderr, "Warning: %s budget of %s '%s' exceeded (%s)\n", period.name, kind, name, exceeded)
+				continue
+			}
+			return fmt.Errorf("%w: %s budget of %s '%s' (%s)", ErrBudgetExceeded, period.name, kind, name, exceeded)
+		}
+		if remaining != "" {
+			fmt.Fprintf(os.Stderr, "Budget: %s remaining in the %s budget of %s '%s'\n", remaining, period.name, kind, name)
+		}
+	}
+	return nil
+}
+
+// estimateUsage estimates the prompt tokens of a call before it is made
+func estimateUsage(messages []api.Message) api.Usage {
+	return api.Usage{PromptTokens: estimateMessageTokens(messages)}
+}
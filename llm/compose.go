@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultInputTemplate joins an instruction given on the command line with a
+// document piped on stdin
+const DefaultInputTemplate = `{{.Instruction}}
+
+<document>
+{{.Input}}
+</document>`
+
+// ComposeInput combines an instruction and a document into one user
+// message using tmpl, a text/template with the fields .Instruction and
+// .Input. An empty tmpl selects DefaultInputTemplate. If only one of the two
+// is given it is returned unchanged.
+func ComposeInput(tmpl, instruction, document string) (string, error) {
+	instruction = strings.TrimSpace(instruction)
+	document = strings.TrimRight(document, "\r\n")
+	if instruction == "" {
+		return document, nil
+	}
+	if strings.TrimSpace(document) == "" {
+		return instruction, nil
+	}
+
+	if tmpl == "" {
+		tmpl = DefaultInputTemplate
+	}
+	t, err := template.New("input").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid input template: %v", err)
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, struct{ Instruction, Input string }{instruction, document})
+	if err != nil {
+		return "", fmt.Errorf("invalid input template: %v", err)
+	}
+	return b.String(), nil
+}
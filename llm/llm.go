@@ -1,9 +1,14 @@
 package llm
 
 import (
+	"errors"
 	"fmt"
 	"llm_cli/config"
 	"llm_cli/llm/api"
+	"llm_cli/utils"
+	"os"
+	"strings"
+	"time"
 )
 
 // Request represents a request to an LLM model
@@ -12,11 +17,232 @@ type Request struct {
 	Messages []api.Message
 }
 
+// CallOptions holds per-call settings for CallWithOptions
+type CallOptions struct {
+	// Params override the generation parameters configured for the model
+	Params api.GenerationParams
+	// OnDelta receives streamed content; nil disables streaming
+	OnDelta api.StreamHandler
+	// Images are attached to the input of SimpleCallWithOptions
+	Images []api.Image
+	// JSON asks for a validated JSON reply; it disables streaming
+	JSON *JSONOutput
+	// Tools are the functions the model may call
+	Tools []api.Tool
+	// fit trims the messages to the context of each model that is tried
+	fit func(name string, model config.ModelConfig, messages []api.Message) ([]api.Message, error)
+}
+
 // Call sends a request to the specified LLM model and returns its response
 func Call(modelName string, messages []api.Message) (string, error) {
+	return CallWithOptions(modelName, messages, CallOptions{})
+}
+
+// StreamCall sends a request to the specified LLM model, passing content
+// deltas to onDelta as they arrive
+func StreamCall(modelName string, messages []api.Message, onDelta api.StreamHandler) (string, error) {
+	return CallWithOptions(modelName, messages, CallOptions{OnDelta: onDelta})
+}
+
+// CallWithOptions sends a request to the specified LLM model with per-call
+// settings. Providers without streaming support deliver the whole response
+// to OnDelta as a single delta.
+func CallWithOptions(modelName string, messages []api.Message, opts CallOptions) (string, error) {
+	if opts.JSON != nil {
+		opts.OnDelta = nil // Invalid replies are retried, so nothing is streamed
+	}
+
+	response, _, err := callWithOutput(messages, opts.JSON, func(messages []api.Message) (api.Response, string, error) {
+		response, answeredBy, err := callWithFallback(modelName, messages, opts)
+		if err != nil {
+			return api.Response{}, "", err
+		}
+
+		history, err := utils.NewHistory()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+			return response, answeredBy, nil
+		}
+		defer history.Close()
+		recordUsage(history, "", answeredBy, response.Usage)
+		return response, answeredBy, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.Content, nil
+}
+
+// recordUsage stores the token usage and cost of a call. Failing to record
+// usage must not fail the call, so errors are only reported as warnings.
+func recordUsage(history *utils.History, assistant, modelName string, usage api.Usage) {
 	cfg, err := config.GetConfig()
 	if err != nil {
-		return "", fmt.Errorf("failed to get config: %v", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+		return
+	}
+
+	err = history.AddUsage(utils.UsageRecord{
+		Assistant:        assistant,
+		Model:            modelName,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             cfg.Models[modelName].Price.Cost(usage),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+	}
+}
+
+// callWithFallback calls the model and, if it is over budget or fails with a
+// retryable error before producing any output, each model of its fallback
+// list in turn. It returns the name of the model that answered.
+func callWithFallback(modelName string, messages []api.Message, opts CallOptions) (api.Response, string, error) {
+	chain, models, err := modelChain(modelName)
+	if err != nil {
+		return api.Response{}, "", err
+	}
+
+	var failures []string
+	for i, candidate := range chain {
+		// Once output has been streamed, switching models would mix answers
+		streamed := false
+		callOpts := opts
+		if opts.OnDelta != nil {
+			callOpts.OnDelta = func(delta string) {
+				streamed = true
+				opts.OnDelta(delta)
+			}
+		}
+
+		model := models[candidate]
+		messages := messages
+		if opts.fit != nil {
+			messages, err = opts.fit(candidate, model, messages)
+			if err != nil {
+				return api.Response{}, "", err
+			}
+		}
+		if err := checkBudget("model", candidate, model.Budget, estimateUsage(messages), model.Price); err != nil {
+			if !errors.Is(err, ErrBudgetExceeded) || i == len(chain)-1 {
+				return api.Response{}, "", err
+			}
+			failures = append(failures, err.Error())
+			fmt.Fprintf(os.Stderr, "Skipping model '%s': %v\n", candidate, err)
+			continue
+		}
+
+		response, err := callModel(model, messages, callOpts)
+		if err == nil {
+			if i > 0 {
+				fmt.Fprintf(os.Stderr, "Answered by fallback model '%s'\n", candidate)
+			}
+			return response, candidate, nil
+		}
+
+		if streamed || !api.IsRetryable(err) || i == len(chain)-1 {
+			if i > 0 {
+				return api.Response{}, "", fmt.Errorf("model '%s' failed: %w (after %s)", candidate, err, strings.Join(failures, "; "))
+			}
+			return api.Response{}, "", err
+		}
+
+		failures = append(failures, fmt.Sprintf("'%s' failed: %v", candidate, err))
+		fmt.Fprintf(os.Stderr, "Model '%s' failed, trying '%s': %v\n", candidate, chain[i+1], err)
+	}
+
+	return api.Response{}, "", fmt.Errorf("no model available")
+}
+
+// modelChain returns the names of a model and of its fallback models in the
+// order they are tried, with their configs
+func modelChain(modelName string) ([]string, map[string]config.ModelConfig, error) {
+	name, model, err := resolveModel(modelName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get config: %v", err)
+	}
+
+	chain := []string{name}
+	models := map[string]config.ModelConfig{name: model}
+	for _, fallback := range model.Fallback {
+		if _, seen := models[fallback]; seen {
+			continue
+		}
+		fallbackModel, exists := cfg.Models[fallback]
+		if !exists {
+			return nil, nil, fmt.Errorf("fallback model '%s' not found in config", fallback)
+		}
+		chain = append(chain, fallback)
+		models[fallback] = fallbackModel
+	}
+	return chain, models, nil
+}
+
+// callModel sends a single request to a configured model
+func callModel(model config.ModelConfig, messages []api.Message, opts CallOptions) (api.Response, error) {
+	provider, exists := api.Providers[model.API]
+	if !exists {
+		return api.Response{}, fmt.Errorf("unsupported API provider: %s", model.API)
+	}
+
+	req := newRequest(model, messages)
+	req.Params = model.GenerationParams.Merge(opts.Params)
+	req.Tools = opts.Tools
+	if opts.JSON != nil {
+		// Providers without a JSON mode rely on the instruction in the input
+		req.Params.ResponseFormat, req.Params.Schema = "", nil
+		if _, ok := provider.(api.JSONModeProvider); ok {
+			req.Params = req.Params.Merge(opts.JSON.params())
+		}
+	}
+	if err := req.Params.Validate(); err != nil {
+		return api.Response{}, err
+	}
+
+	onDelta := opts.OnDelta
+	if onDelta == nil {
+		return provider.Call(req)
+	}
+
+	if streamer, ok := provider.(api.StreamProvider); ok {
+		return streamer.Stream(req, onDelta)
+	}
+
+	response, err := provider.Call(req)
+	if err != nil {
+		return api.Response{}, err
+	}
+	onDelta(response.Content)
+	return response, nil
+}
+
+// newRequest builds the provider request for a configured model
+func newRequest(model config.ModelConfig, messages []api.Message) api.Request {
+	return api.Request{
+		Model:             model.Model,
+		Messages:          messages,
+		APIKey:            model.API_KEY,
+		BaseURL:           model.BaseURL,
+		Headers:           model.Headers,
+		QueryParams:       model.QueryParams,
+		Timeout:           time.Duration(model.Timeout) * time.Second,
+		MaxAttempts:       model.MaxAttempts,
+		StreamUsage:       model.StreamUsage,
+		StructuredOutputs: model.StructuredOutputs,
+	}
+}
+
+// resolveModel looks up a model by name, falling back to the default model.
+// It returns the name of the model actually used.
+func resolveModel(modelName string) (string, config.ModelConfig, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", config.ModelConfig{}, fmt.Errorf("failed to get config: %v", err)
 	}
 
 	model, exists := cfg.Models[modelName]
@@ -24,25 +250,56 @@ func Call(modelName string, messages []api.Message) (string, error) {
 		if cfg.Default != "" {
 			model, exists = cfg.Models[cfg.Default]
 			if !exists {
-				return "", fmt.Errorf("default model '%s' not found in config", cfg.Default)
+				return "", config.ModelConfig{}, fmt.Errorf("default model '%s' not found in config", cfg.Default)
 			}
+			modelName = cfg.Default
 		} else {
-			return "", fmt.Errorf("model '%s' not found in config", modelName)
+			return "", config.ModelConfig{}, fmt.Errorf("model '%s' not found in config", modelName)
 		}
 	}
 
-	provider, exists := api.Providers[model.API]
-	if !exists {
-		return "", fmt.Errorf("unsupported API provider: %s", model.API)
-	}
-
-	return provider.Call(model.Model, messages, model.API_KEY)
+	return modelName, model, nil
 }
 
 // SimpleCall is a helper function for simple single-message calls
 func SimpleCall(modelName string, input string) (string, error) {
+	return SimpleCallWithOptions(modelName, input, CallOptions{})
+}
+
+// SimpleStreamCall is the streaming variant of SimpleCall
+func SimpleStreamCall(modelName string, input string, onDelta api.StreamHandler) (string, error) {
+	return SimpleCallWithOptions(modelName, input, CallOptions{OnDelta: onDelta})
+}
+
+// SimpleCallWithOptions is SimpleCall with per-call settings
+func SimpleCallWithOptions(modelName string, input string, opts CallOptions) (string, error) {
 	messages := []api.Message{
-		{Role: "user", Content: input},
+		{Role: "user", Content: input, Images: opts.Images},
+	}
+	return CallWithOptions(modelName, messages, opts)
+}
+
+// ListModels returns the models available to the provider of a configured
+// model and whether the configured model is among them
+func ListModels(modelName string) ([]string, bool, error) {
+	_, model, err := resolveModel(modelName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	provider, exists := api.Providers[model.API]
+	if !exists {
+		return nil, false, fmt.Errorf("unsupported API provider: %s", model.API)
 	}
-	return Call(modelName, messages)
-} 
\ No newline at end of file
+
+	lister, ok := provider.(api.ModelLister)
+	if !ok {
+		return nil, false, fmt.Errorf("provider %s cannot list models", model.API)
+	}
+
+	available, err := lister.ListModels(newRequest(model, nil))
+	if err != nil {
+		return nil, false, err
+	}
+	return available, lister.HasModel(available, model.Model), nil
+}
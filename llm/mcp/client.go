@@ -0,0 +1,323 @@
+// Package mcp is a client for Model Context Protocol servers launched as
+// subprocesses. Messages are JSON-RPC 2.0, one per line on the server's
+// stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"llm_cli/config"
+)
+
+const (
+	// protocolVersion is the MCP revision requested during initialization
+	protocolVersion = "2025-03-26"
+	// defaultTimeout bounds how long to wait for a reply by default
+	defaultTimeout = 30 * time.Second
+	// closeTimeout is how long a server may take to exit once its stdin is closed
+	closeTimeout = 2 * time.Second
+	// maxMessageSize bounds the size of a single message from a server
+	maxMessageSize = 16 * 1024 * 1024
+	// maxStderr bounds how much of the server's stderr is kept for errors
+	maxStderr = 4 * 1024
+)
+
+// Client is a connection to a running MCP server
+type Client struct {
+	name    string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	stderr  *tailBuffer
+	timeout time.Duration
+
+	mu      sync.Mutex // guards writes, nextID and pending
+	nextID  int64
+	pending map[int64]chan response
+
+	done    chan struct{} // closed when the server's stdout ends
+	readErr error
+
+	capabilities capabilities
+}
+
+// capabilities are the features a server declared during initialization;
+// a feature is supported if its field is present
+type capabilities struct {
+	Tools     *json.RawMessage `json:"tools"`
+	Resources *json.RawMessage `json:"resources"`
+}
+
+// request is a JSON-RPC request, or a notification if it has no ID
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response to one of our requests
+type response struct {
+	Result json.RawMessage
+	Error  *rpcError
+}
+
+// rpcError is the error of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// incoming is any message sent by the server: a response, a request or a
+// notification
+type incoming struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// Start launches the server and completes the initialization handshake
+func Start(name string, server config.MCPServerConfig) (*Client, error) {
+	if strings.TrimSpace(server.Command) == "" {
+		return nil, fmt.Errorf("MCP server '%s' has no command", name)
+	}
+
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range server.Env {
+		cmd.Env = append(cmd.Env, key+"="+os.ExpandEnv(value))
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  &tailBuffer{},
+		timeout: defaultTimeout,
+		pending: make(map[int64]chan response),
+		done:    make(chan struct{}),
+	}
+	if server.Timeout > 0 {
+		c.timeout = time.Duration(server.Timeout) * time.Second
+	}
+	cmd.Stderr = c.stderr
+	// A grandchild may keep stderr open after the server exits
+	cmd.WaitDelay = closeTimeout
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server '%s': %v", name, err)
+	}
+	go c.read(stdout)
+
+	params := map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "llmcli", "version": "1.0"},
+	}
+	var initialized struct {
+		Capabilities capabilities `json:"capabilities"`
+	}
+	if err := c.call("initialize", params, &initialized); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.capabilities = initialized.Capabilities
+	if err := c.notify("notifications/initialized"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name returns the name of the server in the config
+func (c *Client) Name() string { return c.name }
+
+// HasTools reports whether the server declared that it offers tools
+func (c *Client) HasTools() bool { return c.capabilities.Tools != nil }
+
+// HasResources reports whether the server declared that it offers resources
+func (c *Client) HasResources() bool { return c.capabilities.Resources != nil }
+
+// Close closes the server's stdin and waits for it to exit, killing it if
+// it does not exit in time. If stdout stays open after that, e.g. because
+// a process started by the server still holds it, the pipe is closed
+// rather than waited on.
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(closeTimeout):
+		c.cmd.Process.Kill()
+		select {
+		case <-c.done:
+		case <-time.After(closeTimeout):
+			c.stdout.Close()
+			<-c.done
+		}
+	}
+	c.cmd.Wait()
+	return nil
+}
+
+// call sends a request and decodes the result of the response into result
+// unless it is nil
+func (c *Client) call(method string, params interface{}, result interface{}) error {
+	replies := make(chan response, 1)
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = replies
+	err := c.write(request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		// Writing fails if the server has exited, which says more
+		select {
+		case <-c.done:
+			return fmt.Errorf("MCP server '%s' exited%s", c.name, c.exitReason())
+		case <-time.After(closeTimeout):
+			return fmt.Errorf("MCP server '%s': %s: %v", c.name, method, err)
+		}
+	}
+
+	select {
+	case reply := <-replies:
+		if reply.Error != nil {
+			return fmt.Errorf("MCP server '%s': %s: %s (code %d)", c.name, method, reply.Error.Message, reply.Error.Code)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(reply.Result, result); err != nil {
+			return fmt.Errorf("MCP server '%s': %s: invalid result: %v", c.name, method, err)
+		}
+		return nil
+	case <-c.done:
+		return fmt.Errorf("MCP server '%s' exited%s", c.name, c.exitReason())
+	case <-time.After(c.timeout):
+		return fmt.Errorf("MCP server '%s': %s: no reply after %s", c.name, method, c.timeout)
+	}
+}
+
+// notify sends a notification, which gets no response
+func (c *Client) notify(method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.write(request{JSONRPC: "2.0", Method: method}); err != nil {
+		return fmt.Errorf("MCP server '%s': %s: %v", c.name, method, err)
+	}
+	return nil
+}
+
+// write sends one message; c.mu must be held
+func (c *Client) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// read dispatches the messages of the server until its stdout ends
+func (c *Client) read(stdout io.Reader) {
+	defer close(c.done)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var message incoming
+		if err := json.Unmarshal(line, &message); err != nil {
+			continue // Not JSON-RPC, e.g. a stray log line
+		}
+		hasID := len(message.ID) > 0 && string(message.ID) != "null"
+		switch {
+		case message.Method != "" && hasID:
+			c.answer(message)
+		case message.Method != "":
+			// Notifications such as logging or list changes are not used
+		case hasID:
+			var id int64
+			if err := json.Unmarshal(message.ID, &id); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			replies, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				replies <- response{Result: message.Result, Error: message.Error}
+			}
+		}
+	}
+	c.readErr = scanner.Err()
+}
+
+// answer replies to a request from the server. Only pings are supported
+// since the client declares no capabilities.
+func (c *Client) answer(message incoming) {
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": message.ID}
+	if message.Method == "ping" {
+		reply["result"] = map[string]interface{}{}
+	} else {
+		reply["error"] = rpcError{Code: -32601, Message: "method not found: " + message.Method}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.write(reply)
+}
+
+// exitReason describes why the server's output ended, for error messages
+func (c *Client) exitReason() string {
+	if c.readErr != nil {
+		return ": " + c.readErr.Error()
+	}
+	if stderr := strings.TrimSpace(c.stderr.String()); stderr != "" {
+		return ": " + stderr
+	}
+	return ""
+}
+
+// tailBuffer keeps the end of what is written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > maxStderr {
+		b.data = b.data[len(b.data)-maxStderr:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
@@ -0,0 +1,359 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"llm_cli/llm/tools"
+)
+
+// maxToolName is the longest tool name the providers accept
+const maxToolName = 64
+
+// invalidNameChars matches what providers do not accept in tool names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolInfo describes a tool offered by a server
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	} `json:"annotations"`
+}
+
+// Resource describes a resource offered by a server
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+// content is an item of the content of a tool result or resource
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	URI      string `json:"uri"`
+	Blob     string `json:"blob"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Blob     string `json:"blob"`
+	} `json:"resource"`
+}
+
+// ListTools returns the tools offered by the server
+func (c *Client) ListTools() ([]ToolInfo, error) {
+	var all []ToolInfo
+	cursor := ""
+	for {
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call("tools/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool runs a tool with the JSON object of arguments and returns the
+// text of its result. A result the server flags as an error is returned as
+// an error.
+func (c *Client) CallTool(name string, arguments json.RawMessage) (string, error) {
+	if len(strings.TrimSpace(string(arguments))) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	if err := c.call("tools/call", params, &result); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, item := range result.Content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil && item.Resource.Blob == "":
+			parts = append(parts, item.Resource.Text)
+		case item.Type == "resource" && item.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[binary resource %s]", item.Resource.URI))
+		case item.Type == "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s]", item.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", item.Type, item.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+// ListResources returns the resources offered by the server
+func (c *Client) ListResources() ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var result struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call("resources/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Resources...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// ReadResource returns the text of a resource; binary contents are only
+// described
+func (c *Client) ReadResource(uri string) (string, error) {
+	var result struct {
+		Contents []content `json:"contents"`
+	}
+	if err := c.call("resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, item := range result.Contents {
+		if item.Blob != "" {
+			parts = append(parts, fmt.Sprintf("[binary resource %s %s]", item.URI, item.MimeType))
+			continue
+		}
+		parts = append(parts, item.Text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// cursorParams returns the params of a list request
+func cursorParams(cursor string) interface{} {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+// ToolName returns the name a server's tool is advertised under, prefixed
+// with the server name so that servers cannot shadow each other or the
+// built-in tools
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server+"__"+tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// serverTool calls a tool of a server. Tools not annotated as read-only
+// are treated as having side effects.
+type serverTool struct {
+	client *Client
+	name   string
+	info   ToolInfo
+}
+
+func (t serverTool) Definition() api.Tool {
+	parameters := t.info.InputSchema
+	if len(parameters) == 0 {
+		parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return api.Tool{Name: t.name, Description: t.info.Description, Parameters: parameters}
+}
+
+func (t serverTool) SideEffects() bool { return !t.info.Annotations.ReadOnlyHint }
+
+func (t serverTool) Run(arguments string) (string, error) {
+	output, err := t.client.CallTool(t.info.Name, json.RawMessage(arguments))
+	if err != nil {
+		return "", fmt.Errorf("%s", tools.TruncateOutput(err.Error()))
+	}
+	return tools.TruncateOutput(output), nil
+}
+
+// resourceTool reads the resources of a server
+type resourceTool struct {
+	client    *Client
+	name      string
+	resources []Resource
+}
+
+func (t resourceTool) Definition() api.Tool {
+	var description strings.Builder
+	fmt.Fprintf(&description, "Read a resource of the %s server. Available resources:", t.client.Name())
+	uris := make([]string, 0, len(t.resources))
+	for _, resource := range t.resources {
+		uris = append(uris, resource.URI)
+		fmt.Fprintf(&description, "\n- %s", resource.URI)
+		if resource.Name != "" {
+			fmt.Fprintf(&description, " (%s)", resource.Name)
+		}
+		if resource.Description != "" {
+			fmt.Fprintf(&description, ": %s", resource.Description)
+		}
+	}
+	enum, _ := json.Marshal(uris)
+	return api.Tool{
+		Name:        t.name,
+		Description: description.String(),
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"uri":{"type":"string","enum":` + string(enum) + `}},"required":["uri"]}`),
+	}
+}
+
+func (resourceTool) SideEffects() bool { return false }
+
+func (t resourceTool) Run(arguments string) (string, error) {
+	var args struct {
+		URI string `json:"uri"`
+	}
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	if args.URI == "" {
+		return "", fmt.Errorf("missing uri")
+	}
+	output, err := t.client.ReadResource(args.URI)
+	if err != nil {
+		return "", err
+	}
+	return tools.TruncateOutput(output), nil
+}
+
+// Servers are the MCP servers of a process or interactive session. Each
+// server is started the first time an assistant uses it and kept running,
+// with its state, until Close.
+type Servers struct {
+	configured map[string]config.MCPServerConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client
+	tools   map[string]map[string]tools.Tool // by server
+}
+
+// NewServers returns the servers of the config without starting any
+func NewServers(configured map[string]config.MCPServerConfig) *Servers {
+	return &Servers{
+		configured: configured,
+		clients:    make(map[string]*Client),
+		tools:      make(map[string]map[string]tools.Tool),
+	}
+}
+
+// StartServers starts the named servers from the config right away and
+// returns them with their tools
+func StartServers(names []string, configured map[string]config.MCPServerConfig) (*Servers, map[string]tools.Tool, error) {
+	servers := NewServers(configured)
+	available, err := servers.Tools(names)
+	if err != nil {
+		servers.Close()
+		return nil, nil, err
+	}
+	return servers, available, nil
+}
+
+// Tools returns the tools of the named servers, starting those that are
+// not running yet and discovering their tools and resources. Each server's
+// tools are named by ToolName and its resources are read with the
+// <server>__read_resource tool.
+func (s *Servers) Tools(names []string) (map[string]tools.Tool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available := make(map[string]tools.Tool)
+	for _, name := range names {
+		serverTools, running := s.tools[name]
+		if !running {
+			server, ok := s.configured[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown MCP server '%s'", name)
+			}
+			client, err := Start(name, server)
+			if err != nil {
+				return nil, err
+			}
+			serverTools = make(map[string]tools.Tool)
+			if err := discover(client, serverTools); err != nil {
+				client.Close()
+				return nil, err
+			}
+			s.clients[name] = client
+			s.tools[name] = serverTools
+		}
+		for toolName, tool := range serverTools {
+			available[toolName] = tool
+		}
+	}
+	return available, nil
+}
+
+// discover adds the tools and resources of a server to available, listing
+// only what the server declared in its capabilities
+func discover(client *Client, available map[string]tools.Tool) error {
+	if client.HasTools() {
+		infos, err := client.ListTools()
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			name := ToolName(client.Name(), info.Name)
+			if _, exists := available[name]; exists {
+				return fmt.Errorf("MCP server '%s' has two tools named '%s'", client.Name(), name)
+			}
+			available[name] = serverTool{client: client, name: name, info: info}
+		}
+	}
+
+	if !client.HasResources() {
+		return nil
+	}
+	resources, err := client.ListResources()
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	name := ToolName(client.Name(), "read_resource")
+	if _, exists := available[name]; !exists {
+		available[name] = resourceTool{client: client, name: name, resources: resources}
+	}
+	return nil
+}
+
+// Close stops all running servers
+func (s *Servers) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, client := range s.clients {
+		client.Close()
+		delete(s.clients, name)
+		delete(s.tools, name)
+	}
+}
@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"llm_cli/utils"
+)
+
+// PromptVars are the values prompt templates are rendered with, besides
+// the built-in ones
+type PromptVars struct {
+	// Vars are set on the command line with --var name=value and used as {{.name}}
+	Vars map[string]string
+	// Stdin is the input piped to llmcli, used as {{.Stdin}}
+	Stdin string
+	// Files are the paths or globs {{file "path"}} may include, relative
+	// ones resolved against the working directory; other files are refused
+	Files []string
+}
+
+// RenderPrompt renders text as a text/template. Templates can use
+// {{.Date}}, {{.Time}}, {{.Cwd}}, {{.OS}}, {{.GitBranch}}, {{.Stdin}} and the
+// variables in vars, which take precedence, as well as {{file "path"}} to
+// include a text file listed in vars.Files and {{env "NAME"}} for an
+// environment variable. Using an unset variable is an error. Text without
+// actions is returned unchanged.
+func RenderPrompt(name, text string, vars PromptVars) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := parsePrompt(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	t.Funcs(template.FuncMap{"file": func(path string) (string, error) {
+		return includeFile(path, vars.Files)
+	}})
+	var b strings.Builder
+	if err := t.Execute(&b, promptData(t, vars)); err != nil {
+		return "", fmt.Errorf("failed to render template: %v", err)
+	}
+	return b.String(), nil
+}
+
+// PromptUses reports whether the template text refers to the variable
+// {{.name}} in one of its actions
+func PromptUses(text, name string) (bool, error) {
+	if !strings.Contains(text, "{{") {
+		return false, nil
+	}
+	t, err := parsePrompt("prompt", text)
+	if err != nil {
+		return false, err
+	}
+	return templateUses(t, name), nil
+}
+
+// templateUses reports whether t, or a template it defines, refers to the
+// field name of the data
+func templateUses(t *template.Template, name string) bool {
+	for _, defined := range t.Templates() {
+		if defined.Tree != nil && usesField(defined.Tree.Root, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrompt parses text as a prompt template. {{file}} includes nothing
+// until RenderPrompt allows the files of its vars.
+func parsePrompt(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"file": func(path string) (string, error) { return includeFile(path, nil) },
+		"env":  os.Getenv,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	return t, nil
+}
+
+// usesField reports whether node refers to the field name of the data, as
+// {{.name}} or {{$.name}}
+func usesField(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesField(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesField(n.Pipe, name)
+	case *parse.IfNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.RangeNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.WithNode:
+		return usesField(&n.BranchNode, name)
+	case *parse.BranchNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.TemplateNode:
+		return usesField(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesField(cmd, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesField(arg, name) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return usesField(n.Node, name)
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == name
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	}
+	return false
+}
+
+// promptData returns the values of the built-in and given variables. The
+// Git branch is only looked up if the template refers to it.
+func promptData(t *template.Template, vars PromptVars) map[string]interface{} {
+	now := time.Now()
+	cwd, _ := os.Getwd()
+	data := map[string]interface{}{
+		"Date":  now.Format("2006-01-02"),
+		"Time":  now.Format("15:04"),
+		"Cwd":   cwd,
+		"OS":    runtime.GOOS,
+		"Stdin": vars.Stdin,
+	}
+	if templateUses(t, "GitBranch") {
+		data["GitBranch"] = gitBranch()
+	}
+	for name, value := range vars.Vars {
+		data[name] = value
+	}
+	return data
+}
+
+// gitBranch returns the branch checked out in the working directory, the
+// short commit hash if HEAD is detached, or an empty string outside a Git
+// repository
+func gitBranch() string {
+	output, err := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD").Output()
+	if err != nil {
+		output, err = exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+		if err != nil {
+			return ""
+		}
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// includeFile returns the content of a text file for {{file "path"}} if
+// the file matches one of the allowed paths or globs. A symbolic link is
+// only followed to a file that is allowed as well.
+func includeFile(path string, allowed []string) (string, error) {
+	if !fileAllowed(path, allowed) {
+		return "", fmt.Errorf("%s is not listed in promptFiles", path)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", err
+		}
+		if !fileAllowed(target, allowed) {
+			return "", fmt.Errorf("%s links to %s, which is not listed in promptFiles", path, target)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if utils.IsBinary(content) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(content), nil
+}
+
+// fileAllowed reports whether path matches one of the allowed paths or
+// globs, comparing absolute paths with their directories' links resolved
+func fileAllowed(path string, allowed []string) bool {
+	path = canonicalPath(path)
+	for _, pattern := range allowed {
+		if strings.HasPrefix(pattern, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				pattern = filepath.Join(home, pattern[2:])
+			}
+		}
+		if matched, err := filepath.Match(canonicalPath(pattern), path); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath returns path as an absolute path whose directory has its
+// symbolic links resolved if it exists
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return abs
+	}
+	return filepath.Join(dir, filepath.Base(abs))
+}
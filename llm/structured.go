@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"fmt"
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+	"os"
+	"strings"
+)
+
+// jsonRetries is how often a model is asked to correct an invalid JSON reply
+const jsonRetries = 2
+
+// JSONOutput asks for a reply that is a single JSON value. Providers with a
+// JSON mode are asked to use it; every reply is also checked locally.
+type JSONOutput struct {
+	// Schema, if set, is the JSON Schema the reply must satisfy
+	Schema *utils.JSONSchema
+}
+
+// params returns the response format that requests JSON from a provider
+func (o *JSONOutput) params() api.GenerationParams {
+	if o.Schema != nil {
+		return api.GenerationParams{ResponseFormat: api.ResponseFormatJSONSchema, Schema: o.Schema.Raw}
+	}
+	return api.GenerationParams{ResponseFormat: api.ResponseFormatJSON}
+}
+
+// instruction tells the model what to reply with, for providers without a
+// JSON mode and because OpenAI's JSON mode requires the prompt to ask for JSON
+func (o *JSONOutput) instruction() string {
+	if o.Schema == nil {
+		return "Reply with a single JSON value and nothing else."
+	}
+	return "Reply with a single JSON value and nothing else. It must conform to this JSON Schema:\n" + string(o.Schema.Raw)
+}
+
+// extractJSON strips surrounding whitespace and a Markdown code fence, which
+// models without a JSON mode tend to wrap JSON in
+func extractJSON(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") || !strings.HasSuffix(reply, "```") {
+		return reply
+	}
+	body := strings.TrimSuffix(reply, "```")
+	if newline := strings.Index(body, "\n"); newline >= 0 {
+		return strings.TrimSpace(body[newline+1:])
+	}
+	return reply
+}
+
+// callFunc makes one model call, recording its usage, and returns the
+// response and the name of the model that answered
+type callFunc func(messages []api.Message) (api.Response, string, error)
+
+// callWithOutput makes the call and, when output asks for JSON, checks the
+// reply and asks the model to correct it up to jsonRetries times, passing
+// back the validation error. The returned content is the bare JSON.
+func callWithOutput(messages []api.Message, output *JSONOutput, call callFunc) (api.Response, string, error) {
+	if output == nil {
+		return call(messages)
+	}
+
+	// Ask for JSON in the input rather than the system prompt so that the
+	// instruction is the last thing the model reads
+	messages = append([]api.Message{}, messages...)
+	last := &messages[len(messages)-1]
+	last.Content = strings.TrimSpace(last.Content + "\n\n" + output.instruction())
+
+	for attempt := 1; ; attempt++ {
+		response, answeredBy, err := call(messages)
+		if err != nil {
+			return api.Response{}, "", err
+		}
+
+		reply := extractJSON(response.Content)
+		err = output.Schema.Validate([]byte(reply))
+		if err == nil {
+			response.Content = reply
+			return response, answeredBy, nil
+		}
+		if attempt > jsonRetries {
+			return api.Response{}, "", fmt.Errorf("reply is still not valid after %d attempts: %v", attempt, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Reply is not valid, asking for a correction: %v\n", err)
+		messages = append(messages,
+			api.Message{Role: "assistant", Content: response.Content},
+			api.Message{Role: "user", Content: fmt.Sprintf("Your reply is not valid: %v\nReply again with only the corrected JSON.", err)})
+	}
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"fmt"
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+	"os"
+	"strings"
+)
+
+const (
+	// summaryPrefix introduces the session summary injected after the system prompt
+	summaryPrefix = "Summary of the earlier conversation:\n"
+
+	defaultSummarizeAfter = 20
+	minSummarizeKeep      = 4
+
+	defaultSummarizePrompt = `You maintain the long-term memory of a chat assistant. Update the summary of
+the conversation with the new messages below. Keep facts, decisions, names,
+preferences and open questions; drop small talk. Answer with the updated
+summary only, written as concise notes.`
+)
+
+// summarize condenses the older unsummarized messages of a session into its
+// running summary once there are enough of them. The chat call has already
+// succeeded, so failures are only reported as warnings.
+func summarize(history *utils.History, assistantName, session string, assistant config.AssistantConfig, modelName string, summary utils.Summary) {
+	settings := assistant.Summarize
+	after := settings.After
+	if after <= 0 {
+		after = defaultSummarizeAfter
+	}
+	keep := settings.Keep
+	if keep <= 0 {
+		keep = assistant.ChatContextWindow * 2
+		if keep < minSummarizeKeep {
+			keep = minSummarizeKeep
+		}
+	}
+
+	records, err := history.FetchSessionAfter(assistantName, session, summary.LastID, maxContextRecords)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to summarize history: %v\n", err)
+		return
+	}
+	if len(records) < after+keep {
+		return
+	}
+	older := records[:len(records)-keep]
+	// Keep a question together with its answer
+	for len(older) > 0 && older[len(older)-1].Role == "user" {
+		older = older[:len(older)-1]
+	}
+	if len(older) == 0 {
+		return
+	}
+
+	prompt := settings.Prompt
+	if prompt == "" {
+		prompt = defaultSummarizePrompt
+	}
+	var b strings.Builder
+	if summary.Content != "" {
+		fmt.Fprintf(&b, "Current summary:\n%s\n\n", summary.Content)
+	}
+	b.WriteString("New messages:\n")
+	for _, record := range older {
+		fmt.Fprintf(&b, "%s: %s\n", record.Role, record.Content)
+	}
+
+	summaryModel := settings.Model
+	if summaryModel == "" {
+		summaryModel = modelName
+	}
+	response, answeredBy, err := callWithFallback(summaryModel, []api.Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: b.String()},
+	}, CallOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to summarize history: %v\n", err)
+		return
+	}
+	recordUsage(history, assistantName, answeredBy, response.Usage)
+
+	summary.Content = strings.TrimSpace(response.Content)
+	summary.LastID = older[len(older)-1].ID
+	if err := history.SaveSummary(summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Summarized %d older messages of session '%s'\n", len(older), session)
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"fmt"
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// messageOverheadTokens approximates the role and separator tokens each
+	// message adds in the chat formats of the providers
+	messageOverheadTokens = 4
+	// maxContextRecords bounds how much history is loaded when the context
+	// is limited by tokens rather than by a number of turns
+	maxContextRecords = 1000
+)
+
+// EstimateTokens estimates the number of tokens in text from its characters:
+// about one token per four letters of a word, one per punctuation mark and
+// one per CJK character. It is not a tokenizer, so limits based on it keep a
+// margin; it is meant for budgeting, not billing.
+func EstimateTokens(text string) int {
+	tokens := 0
+	word := 0 // letters and digits in the current word
+
+	flush := func() {
+		tokens += (word + 3) / 4
+		word = 0
+	}
+
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// estimateMessageTokens estimates the prompt tokens of messages
+func estimateMessageTokens(messages []api.Message) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += EstimateTokens(message.Content) + messageOverheadTokens
+	}
+	return tokens
+}
+
+// contextFitter trims the chat history of an assistant call to the context
+// of the model that answers it. The messages it fits are the leading system
+// messages, then the history, then the input and anything added after it,
+// such as tool calls and their results, which are always kept.
+type contextFitter struct {
+	leading int
+	history int
+	// window is the number of history messages sent to models without
+	// contextTokens
+	window int
+	params api.GenerationParams
+}
+
+// fit returns the messages to send to the named model, printing a notice
+// on stderr if history was dropped to fit its contextTokens
+func (f contextFitter) fit(name string, model config.ModelConfig, messages []api.Message) ([]api.Message, error) {
+	fitted, dropped, err := f.trim(name, model, messages)
+	if err != nil {
+		return nil, err
+	}
+	if dropped > 0 && model.ContextTokens > 0 {
+		fmt.Fprintf(os.Stderr, "Context truncated: dropped %d older messages to fit the %d token context of model '%s'\n", dropped, model.ContextTokens, name)
+	}
+	return fitted, nil
+}
+
+// trim returns the messages to send to the named model and the number of
+// history messages left out. With contextTokens set on the model the most
+// recent history messages that fit in it, after leaving room for maxTokens
+// of reply, are kept; otherwise the last window messages. It returns an
+// error if the messages that are always kept do not fit.
+func (f contextFitter) trim(name string, model config.ModelConfig, messages []api.Message) ([]api.Message, int, error) {
+	leading := messages[:f.leading]
+	history := messages[f.leading : f.leading+f.history]
+	kept := messages[f.leading+f.history:]
+
+	start := 0
+	if model.ContextTokens <= 0 {
+		if len(history) > f.window {
+			start = len(history) - f.window
+		}
+	} else {
+		limit := model.ContextTokens
+		if maxTokens := model.GenerationParams.Merge(f.params).MaxTokens; maxTokens != nil {
+			limit -= *maxTokens // Leave room for the response
+		}
+		if limit <= 0 {
+			return nil, 0, fmt.Errorf("maxTokens leaves no room for the prompt in the %d token context of model '%s'", model.ContextTokens, name)
+		}
+
+		used := estimateMessageTokens(leading) + estimateMessageTokens(kept)
+		if used > limit {
+			return nil, 0, fmt.Errorf("prompt and input need about %d tokens, more than the %d token context of model '%s'", used, limit, name)
+		}
+
+		// Walk back from the newest message until the next one no longer fits
+		start = len(history)
+		for start > 0 {
+			tokens := estimateMessageTokens(history[start-1 : start])
+			if used+tokens > limit {
+				break
+			}
+			used += tokens
+			start--
+		}
+	}
+	// Do not open the context with a reply whose question was dropped
+	for start > 0 && start < len(history) && history[start].Role == "assistant" {
+		start++
+	}
+
+	fitted := append([]api.Message{}, leading...)
+	fitted = append(fitted, history[start:]...)
+	return append(fitted, kept...), start, nil
+}
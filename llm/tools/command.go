@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"llm_cli/llm/api"
+)
+
+const (
+	// commandTimeout bounds how long run_command waits for a command
+	commandTimeout = 60 * time.Second
+	// maxCommandOutput bounds the output run_command returns
+	maxCommandOutput = 64 * 1024
+	// killWaitDelay is how long to wait for the output of a killed command,
+	// which its child processes may keep open
+	killWaitDelay = time.Second
+)
+
+// runCommand runs a program from the assistant's allow-list. Commands are
+// split into arguments directly, without a shell, so pipes, redirections
+// and variables are not interpreted.
+type runCommand struct {
+	allowed []string
+}
+
+func (c runCommand) Definition() api.Tool {
+	allowed := "none"
+	if len(c.allowed) > 0 {
+		allowed = strings.Join(c.allowed, ", ")
+	}
+	return api.Tool{
+		Name: "run_command",
+		Description: "Run a command in the working directory and return its output. " +
+			"It is not run by a shell: no pipes, redirections or variables. Allowed programs: " + allowed,
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"command":{"type":"string","description":"Command line, e.g. \"go test ./...\""}},` +
+			`"required":["command"]}`),
+	}
+}
+
+func (runCommand) SideEffects() bool { return true }
+
+func (c runCommand) Run(arguments string) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	argv, err := splitCommand(args.Command)
+	if err != nil {
+		return "", err
+	}
+	if len(argv) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	if !c.isAllowed(argv[0]) {
+		return "", fmt.Errorf("'%s' is not an allowed command", argv[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.WaitDelay = killWaitDelay
+	output, err := cmd.CombinedOutput()
+	result := truncate(string(output), maxCommandOutput)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return result + fmt.Sprintf("\n[killed after %s]", commandTimeout), nil
+	case errors.As(err, &exitErr):
+		return result + fmt.Sprintf("\n[%v]", exitErr), nil
+	case err != nil:
+		return "", err
+	}
+	return result, nil
+}
+
+// isAllowed reports whether program is on the allow-list
+func (c runCommand) isAllowed(program string) bool {
+	for _, allowed := range c.allowed {
+		if program == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommand splits a command line into arguments at spaces, keeping
+// single- and double-quoted text together and honoring backslash escapes
+// outside single quotes
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'' && r != '\'':
+			current.WriteRune(r)
+		case r == '\\' && quote != '\'' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote = r
+			inArg = true
+		case quote == 0 && (r == ' ' || r == '\t' || r == '\n'):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+)
+
+// defaultDeclaredTimeout is how long a declared tool may run by default
+const defaultDeclaredTimeout = 30 * time.Second
+
+// placeholder matches the {{name}} placeholders of a command template
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// declaredTool runs the shell command template of a tool declared in the
+// config and returns its stdout
+type declaredTool struct {
+	name   string
+	config config.ToolConfig
+	schema *utils.JSONSchema
+	// command is the template with its placeholders turned into the
+	// positional parameters of sh, which are the arguments named by params
+	command string
+	params  []string
+}
+
+// newDeclaredTool checks the declaration of a tool
+func newDeclaredTool(name string, tool config.ToolConfig) (Tool, error) {
+	if strings.TrimSpace(tool.Command) == "" {
+		return nil, fmt.Errorf("tool '%s' has no command", name)
+	}
+	command, params, err := compileCommand(tool.Command)
+	if err != nil {
+		return nil, fmt.Errorf("tool '%s': %v", name, err)
+	}
+	declared := declaredTool{name: name, config: tool, command: command, params: params}
+	if len(tool.Parameters) > 0 {
+		schema, err := utils.ParseJSONSchema(tool.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("tool '%s' has invalid parameters: %v", name, err)
+		}
+		declared.schema = schema
+	}
+	return declared, nil
+}
+
+func (t declaredTool) Definition() api.Tool {
+	parameters := json.RawMessage(`{"type":"object","properties":{}}`)
+	if t.schema != nil {
+		parameters = t.schema.Raw
+	}
+	return api.Tool{Name: t.name, Description: t.config.Description, Parameters: parameters}
+}
+
+func (t declaredTool) SideEffects() bool { return t.config.SideEffects }
+
+func (t declaredTool) Run(arguments string) (string, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := t.schema.Validate([]byte(arguments)); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	var args map[string]interface{}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	timeout := defaultDeclaredTimeout
+	if t.config.Timeout > 0 {
+		timeout = time.Duration(t.config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	// Arguments are passed as "$1", "$2"... so the shell never parses them
+	shellArgs := []string{"-c", t.command, "sh"}
+	for _, name := range t.params {
+		shellArgs = append(shellArgs, argumentString(args[name]))
+	}
+	cmd := exec.CommandContext(ctx, "sh", shellArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = killWaitDelay
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", fmt.Errorf("timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("%v: %s", exitErr, truncate(message, maxCommandOutput))
+	case err != nil:
+		return "", err
+	}
+	return truncate(stdout.String(), maxCommandOutput), nil
+}
+
+// compileCommand replaces each {{name}} in command with a positional
+// parameter of sh, "${1}" outside quotes and ${1} inside double quotes, and
+// returns the names of the parameters in order. Missing arguments become
+// empty strings. A placeholder inside single quotes cannot be expanded by
+// the shell and is an error.
+func compileCommand(command string) (string, []string, error) {
+	var b strings.Builder
+	var params []string
+	index := make(map[string]int)
+	singleQuoted, doubleQuoted := false, false
+	for i := 0; i < len(command); i++ {
+		if match := placeholderAt(command[i:]); match != nil {
+			if singleQuoted {
+				return "", nil, fmt.Errorf("placeholder %s inside single quotes", command[i:i+match[1]])
+			}
+			name := command[i+match[2] : i+match[3]]
+			if _, seen := index[name]; !seen {
+				params = append(params, name)
+				index[name] = len(params)
+			}
+			param := fmt.Sprintf("${%d}", index[name])
+			if !doubleQuoted {
+				param = `"` + param + `"`
+			}
+			b.WriteString(param)
+			i += match[1] - 1
+			continue
+		}
+
+		c := command[i]
+		switch {
+		case singleQuoted:
+			singleQuoted = c != '\''
+		case c == '\\' && i+1 < len(command):
+			// An escaped character is taken literally
+			b.WriteByte(c)
+			i++
+			c = command[i]
+		case c == '"':
+			doubleQuoted = !doubleQuoted
+		case c == '\'' && !doubleQuoted:
+			singleQuoted = true
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), params, nil
+}
+
+// placeholderAt returns the submatch indexes of the placeholder s starts
+// with, or nil
+func placeholderAt(s string) []int {
+	if !strings.HasPrefix(s, "{{") {
+		return nil
+	}
+	if match := placeholder.FindStringSubmatchIndex(s); match != nil && match[0] == 0 {
+		return match
+	}
+	return nil
+}
+
+// argumentString renders an argument as text: strings as is, other values as JSON
+func argumentString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+)
+
+const (
+	// maxListEntries bounds the entries list_directory returns
+	maxListEntries = 500
+	// maxGrepMatches bounds the lines grep returns
+	maxGrepMatches = 200
+)
+
+// readFile returns the content of a text file
+type readFile struct{}
+
+func (readFile) Definition() api.Tool {
+	return api.Tool{
+		Name:        "read_file",
+		Description: "Read a text file in the working directory",
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"path":{"type":"string","description":"Path relative to the working directory"}},` +
+			`"required":["path"]}`),
+	}
+}
+
+func (readFile) SideEffects() bool { return false }
+
+func (readFile) Run(arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	path, err := workspacePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", args.Path)
+	}
+	if info.Size() > utils.MaxAttachmentSize {
+		return "", fmt.Errorf("%s is larger than %d KB", args.Path, utils.MaxAttachmentSize/1024)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if utils.IsBinary(content) {
+		return "", fmt.Errorf("%s is a binary file", args.Path)
+	}
+	return string(content), nil
+}
+
+// listDirectory lists the entries of a directory, skipping ignored ones
+type listDirectory struct{}
+
+func (listDirectory) Definition() api.Tool {
+	return api.Tool{
+		Name:        "list_directory",
+		Description: "List the files and subdirectories of a directory in the working directory; subdirectories end in /",
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"path":{"type":"string","description":"Directory relative to the working directory, default \".\""}}}`),
+	}
+}
+
+func (listDirectory) SideEffects() bool { return false }
+
+func (listDirectory) Run(arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	dir, err := workspacePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", args.Path, unwrapPathError(err))
+	}
+
+	ignore := utils.LoadGitignore(".")
+	var names []string
+	for _, entry := range entries {
+		if ignore.Ignored(filepath.Join(dir, entry.Name()), entry.IsDir()) {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return "(empty)", nil
+	}
+	if len(names) > maxListEntries {
+		dropped := len(names) - maxListEntries
+		names = append(names[:maxListEntries], fmt.Sprintf("[%d more entries]", dropped))
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+// grep searches text files for lines matching a regular expression
+type grep struct{}
+
+func (grep) Definition() api.Tool {
+	return api.Tool{
+		Name:        "grep",
+		Description: "Search text files in the working directory for lines matching a regular expression (Go syntax); returns path:line: text",
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"pattern":{"type":"string","description":"Regular expression"},` +
+			`"path":{"type":"string","description":"File or directory to search, default \".\""}},` +
+			`"required":["pattern"]}`),
+	}
+}
+
+func (grep) SideEffects() bool { return false }
+
+func (grep) Run(arguments string) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %v", err)
+	}
+	root, err := workspacePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	files := []string{root}
+	if info, err := os.Stat(root); err != nil {
+		return "", err
+	} else if info.IsDir() {
+		if files, err = utils.ListFiles(root); err != nil {
+			return "", err
+		}
+	}
+
+	var matches []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil || len(content) > utils.MaxAttachmentSize || utils.IsBinary(content) {
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), utils.MaxAttachmentSize)
+		for line := 1; scanner.Scan(); line++ {
+			if !re.MatchString(scanner.Text()) {
+				continue
+			}
+			if len(matches) == maxGrepMatches {
+				return strings.Join(matches, "\n") + fmt.Sprintf("\n[stopped after %d matches]", maxGrepMatches), nil
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(file), line, scanner.Text()))
+		}
+	}
+
+	if len(matches) == 0 {
+		return "No matches", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"llm_cli/config"
+	"llm_cli/llm/api"
+)
+
+// Tool is a function an assistant can ask to run
+type Tool interface {
+	// Definition describes the tool to the model
+	Definition() api.Tool
+	// SideEffects reports whether a call may change anything, in which case
+	// the user has to confirm it first
+	SideEffects() bool
+	// Run executes a call with the JSON arguments written by the model and
+	// returns the result to send back
+	Run(arguments string) (string, error)
+}
+
+// Builtin creates the built-in tools by name for an assistant
+var Builtin = map[string]func(assistant config.AssistantConfig) Tool{
+	"read_file":      func(config.AssistantConfig) Tool { return readFile{} },
+	"list_directory": func(config.AssistantConfig) Tool { return listDirectory{} },
+	"grep":           func(config.AssistantConfig) Tool { return grep{} },
+	"run_command": func(assistant config.AssistantConfig) Tool {
+		return runCommand{allowed: assistant.AllowedCommands}
+	},
+}
+
+// ForAssistant returns the tools listed in the assistant's config by name,
+// looking them up among the built-in tools and those declared in the config
+func ForAssistant(assistant config.AssistantConfig, declared map[string]config.ToolConfig) (map[string]Tool, error) {
+	tools := make(map[string]Tool, len(assistant.Tools))
+	for _, name := range assistant.Tools {
+		create, builtin := Builtin[name]
+		declaration, isDeclared := declared[name]
+		switch {
+		case builtin && isDeclared:
+			return nil, fmt.Errorf("tool '%s' is declared in the config but is the name of a built-in tool", name)
+		case builtin:
+			tools[name] = create(assistant)
+		case isDeclared:
+			tool, err := newDeclaredTool(name, declaration)
+			if err != nil {
+				return nil, err
+			}
+			tools[name] = tool
+		default:
+			return nil, fmt.Errorf("unknown tool '%s', available: %s", name, strings.Join(Names(declared), ", "))
+		}
+	}
+	return tools, nil
+}
+
+// Names returns the names of the built-in and declared tools in alphabetical order
+func Names(declared map[string]config.ToolConfig) []string {
+	names := make([]string, 0, len(Builtin)+len(declared))
+	for name := range Builtin {
+		names = append(names, name)
+	}
+	for name := range declared {
+		if _, builtin := Builtin[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseArguments decodes the JSON arguments of a call into args
+func parseArguments(arguments string, args interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), args); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// workspacePath resolves a path given by the model, which must stay inside
+// the current directory so that tools cannot read arbitrary files
+func workspacePath(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || outside(rel) {
+		return "", fmt.Errorf("%s is outside the working directory", path)
+	}
+
+	// Compare real paths too so that symlinks cannot lead outside
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	if realRel, err := filepath.Rel(realRoot, realPath); err != nil || outside(realRel) {
+		return "", fmt.Errorf("%s is outside the working directory", path)
+	}
+	return rel, nil
+}
+
+// outside reports whether a relative path leaves its base directory
+func outside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// unwrapPathError drops the operation and path that os errors repeat
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
+
+// TruncateOutput cuts the output of a tool that is not built in, such as an
+// MCP server's, to the limit of the built-in tools
+func TruncateOutput(output string) string {
+	return truncate(output, maxCommandOutput)
+}
+
+// truncate cuts output to limit bytes, noting how much was dropped
+func truncate(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	return output[:limit] + fmt.Sprintf("\n[truncated %d bytes]", len(output)-limit)
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
 	"llm_cli/config"
 	"llm_cli/llm"
+	"llm_cli/llm/api"
+	"llm_cli/llm/mcp"
 	"llm_cli/utils"
 
 	"github.com/charmbracelet/glamour"
@@ -18,10 +22,39 @@ const (
 	usageTemplate = `Usage:
   llmcli <text>                        - Use default assistant with text
   llmcli -c, --config                 - Edit configuration file
+  llmcli config show [--resolved]     - Print config.json, or the config merged with assistant and prompt files
   llmcli -m, --model <name> <text>    - Call specific model with text
   llmcli -a, --assistant <name> <text> - Call specific assistant with text
+  llmcli -i, --interactive [-a <name>] - Start an interactive chat session
+  llmcli -t, --template <name> [text] - Send a prompt template from the config (no name lists them)
   llmcli -h, --history <name> [n]     - Show chat history for assistant (last n messages)
-  llmcli --clear <name>               - Clear chat history for assistant`
+  llmcli -h <name> --tools [n]        - Show the last n tool calls of assistant with their output
+  llmcli --clear <name>               - Clear chat history for assistant
+  llmcli --list-sessions <name>       - List chat sessions for assistant
+  llmcli --list-models [name]         - List installed models for local providers (Ollama)
+  llmcli --list-mcp [name]            - List the tools and resources of configured MCP servers
+  llmcli usage [--since 7d] [--by model|assistant|day] - Show token usage and cost
+  llmcli search <query> [--assistant <name>] [--role user|assistant] [--since <date>] - Search chat history
+  llmcli export [--assistant <name>] [--session <name>] [--format markdown|json|jsonl|html] [-o <file>] - Export chat history
+  llmcli import <file>                - Import chat history exported as json or jsonl
+
+Options (before the prompt; -- ends them):
+  --session <name>                    - Use a named session for this call
+  --new-session[=<name>]              - Start a new session and make it current
+  --resume <name>                     - Make an existing session current
+  --temperature <t>, --top-p <p>      - Sampling parameters for this call
+  --max-tokens <n>, --seed <n>        - Limit response length / fix the sampling seed
+  --stop <text>                       - Stop sequence (repeatable)
+  --presence-penalty <p>              - Presence penalty for this call
+  --frequency-penalty <p>             - Frequency penalty for this call
+  --response-format <text|json_object> - Request plain text or a JSON object
+  -f, --file <path|dir|glob>          - Attach files, e.g. -f main.go -f 'src/**/*.go' (repeatable)
+  --image <path|url>                  - Attach an image for vision models (repeatable)
+  --json                              - Reply with raw JSON, validated and printed without rendering
+  --schema <file.json>                - Reply with JSON matching a JSON Schema (implies --json)
+  --var <name=value>                  - Set a variable of prompt templates (repeatable)
+  --since <7d|12h|YYYY-MM-DD>         - Period of the usage report (default: all time)
+  --by <model|assistant|day>          - Grouping of the usage report (default: model)`
 )
 
 func getInput() string {
@@ -41,63 +74,83 @@ func getInput() string {
 }
 
 func main() {
-	if len(os.Args) == 1 {
+	flags, args, err := parseFlags(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		showUsage()
+		return
+	}
+
+	if len(args) == 0 {
 		// Check for pipe input
-		if input := getInput(); input != "" {
-			handleAssistantCall("", input)
+		if input := getInput(); input != "" || flags.switchesSession() || len(flags.Files) > 0 || len(flags.Images) > 0 {
+			handleAssistantCall("", "", input, flags)
 			return
 		}
 		showUsage()
 		return
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "-c", "--config":
 		config.HandleConfig()
+	case "config":
+		handleConfigCommand(args[1:])
 	case "-d", "--debug":
 		debug()
+	case "-i", "--interactive":
+		handleInteractive(args[1:], flags)
 	case "-h", "--history":
-		handleHistory(os.Args[2:])
+		handleHistory(args[1:], flags)
 	case "--clear":
-		handleClearHistory(os.Args[2:])
+		handleClearHistory(args[1:])
+	case "--list-sessions":
+		handleListSessions(args[1:])
+	case "--list-models":
+		handleListModels(args[1:])
+	case "--list-mcp":
+		handleListMCP(args[1:])
+	case "usage", "--usage":
+		handleUsage(flags)
+	case "search", "--search":
+		handleSearch(args[1:], flags)
+	case "export", "--export":
+		handleExport(args[1:], flags)
+	case "import", "--import":
+		handleImport(args[1:])
 	case "-m", "--model":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Error: Model name required")
 			fmt.Println("Usage: llmcli -m <model_name> [input_text]")
 			return
 		}
-		modelName := os.Args[2]
-		input := getInput() // Check pipe input first
-		if input == "" && len(os.Args) > 3 {
-			input = strings.Join(os.Args[3:], " ") // Use remaining args as input
-		}
-		if input == "" {
+		modelName := args[1]
+		document := getInput()
+		instruction := strings.Join(args[2:], " ")
+		if document == "" && instruction == "" && len(flags.Files) == 0 && len(flags.Images) == 0 {
 			fmt.Println("Error: No input provided")
 			return
 		}
-		handleModelCall(modelName, input)
+		handleModelCall(modelName, instruction, document, flags)
 	case "-a", "--assistant":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Error: Assistant name required")
 			fmt.Println("Usage: llmcli -a <assistant_name> [input_text]")
 			return
 		}
-		assistantName := os.Args[2]
-		input := getInput() // Check pipe input first
-		if input == "" && len(os.Args) > 3 {
-			input = strings.Join(os.Args[3:], " ") // Use remaining args as input
-		}
-		if input == "" {
-			fmt.Println("Error: No input provided")
+		assistantName := args[1]
+		document := getInput()
+		instruction := strings.Join(args[2:], " ")
+		handleAssistantCall(assistantName, instruction, document, flags)
+	case "-t", "--template":
+		if len(args) < 2 {
+			listTemplates()
 			return
 		}
-		handleAssistantCall(assistantName, input)
+		handleTemplateCall(args[1], strings.Join(args[2:], " "), getInput(), flags)
 	default:
-		input := getInput() // Check pipe input first
-		if input == "" {
-			input = strings.Join(os.Args[1:], " ") // Use all args as input
-		}
-		handleAssistantCall("", input)
+		// Use all args as the instruction for piped input
+		handleAssistantCall("", strings.Join(args, " "), getInput(), flags)
 	}
 }
 
@@ -105,6 +158,16 @@ func showUsage() {
 	fmt.Println(usageTemplate)
 }
 
+// printResponse prints JSON replies as is, so they can be piped into tools
+// like jq, and renders everything else as Markdown
+func printResponse(response string, flags callFlags) {
+	if flags.JSON {
+		fmt.Println(response)
+		return
+	}
+	renderResponse(response)
+}
+
 func renderResponse(response string) {
 	r, err := glamour.NewTermRenderer(
 		glamour.WithAutoStyle(),
@@ -126,47 +189,312 @@ func renderResponse(response string) {
 	fmt.Print(out)
 }
 
-func handleModelCall(modelName, input string) {
-	response, err := llm.SimpleCall(modelName, input)
+// handleModelCall sends the instruction given on the command line together
+// with the document piped on stdin to a model
+func handleModelCall(modelName, instruction, document string, flags callFlags) {
+	input, err := llm.ComposeInput("", instruction, document)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	renderResponse(response)
+	input, err = attachFiles(input, flags.Files)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	images, err := loadImages(flags.Images)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	stream := newStreamPrinter()
+	response, err := llm.SimpleCallWithOptions(modelName, input, llm.CallOptions{
+		Params:  flags.Params,
+		OnDelta: stream.handler(),
+		Images:  images,
+		JSON:    flags.jsonOutput(),
+	})
+	stream.finish()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if !stream.kept {
+		printResponse(response, flags)
+	}
 }
 
-func handleAssistantCall(assistantName string, input string) {
-	var response string
-	var err error
+// handleAssistantCall sends the instruction given on the command line
+// together with the document piped on stdin to an assistant, joined by the
+// assistant's input template
+func handleAssistantCall(assistantName, instruction, document string, flags callFlags) {
+	callAssistant(assistantName, instruction, document, document, flags)
+}
 
+// callAssistant is handleAssistantCall with the piped input, which the
+// assistant's prompt can use as {{.Stdin}}, given apart from the document
+// attached to the message. The document is dropped if the prompt already
+// includes the piped input.
+func callAssistant(assistantName, instruction, document, stdin string, flags callFlags) {
 	if assistantName == "" {
-		response, err = llm.SimpleAssistantCall(input)
-	} else {
-		response, err = llm.AssistantCall(assistantName, input)
+		name, err := llm.DefaultAssistant()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		assistantName = name
+	}
+
+	if err := switchSession(assistantName, flags); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+	promptUsesStdin := false
+	if assistant := cfg.Assistants[assistantName]; assistant.PromptTemplate {
+		promptUsesStdin, err = llm.PromptUses(assistant.Prompt, "Stdin")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+	if promptUsesStdin {
+		document = ""
+	}
+	input, err := llm.ComposeInput(cfg.Assistants[assistantName].InputTemplate, instruction, document)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	input, err = attachFiles(input, flags.Files)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	images, err := loadImages(flags.Images)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if input == "" && len(images) == 0 {
+		switch {
+		case promptUsesStdin && strings.TrimSpace(stdin) != "":
+			fmt.Printf("Error: the prompt of assistant '%s' includes the piped input, give an instruction as well\n", assistantName)
+		case !flags.switchesSession():
+			fmt.Println("Error: No input provided")
+		}
+		return
 	}
 
+	servers := mcp.NewServers(cfg.MCPServers)
+	defer servers.Close()
+
+	stream := newStreamPrinter()
+	response, err := llm.AssistantCallWithOptions(assistantName, input, llm.AssistantOptions{
+		Session:     flags.Session,
+		Params:      flags.Params,
+		OnDelta:     stream.handler(),
+		Images:      images,
+		JSON:        flags.jsonOutput(),
+		Confirm:     utils.Confirm,
+		OnToolCalls: stream.finish,
+		Vars:        llm.PromptVars{Vars: flags.Vars, Stdin: stdin},
+		MCPServers:  servers,
+	})
+	stream.finish()
+
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	renderResponse(response)
+	if !stream.kept {
+		printResponse(response, flags)
+	}
+}
+
+// handleTemplateCall renders a prompt template from the config and sends it
+// to the template's assistant. Text given on the command line is appended.
+// Piped input is attached as a document unless the template uses {{.Stdin}}.
+func handleTemplateCall(name, text, stdin string, flags callFlags) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+	tmpl, exists := cfg.Templates[name]
+	if !exists {
+		fmt.Printf("Error: template '%s' not found in config\n", name)
+		return
+	}
+
+	title := fmt.Sprintf("template '%s'", name)
+	input, err := llm.RenderPrompt(title, tmpl.Template, llm.PromptVars{Vars: flags.Vars, Stdin: stdin, Files: cfg.PromptFiles})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		input = strings.TrimRight(input, "\n") + "\n\n" + text
+	}
+	usesStdin, err := llm.PromptUses(tmpl.Template, "Stdin")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	document := stdin
+	if usesStdin {
+		document = ""
+	}
+	callAssistant(tmpl.Assistant, input, document, stdin, flags)
+}
+
+// listTemplates prints the prompt templates of the config
+func listTemplates() {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+	if len(cfg.Templates) == 0 {
+		fmt.Println("No templates configured")
+		return
+	}
+
+	names := make([]string, 0, len(cfg.Templates))
+	for name := range cfg.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if description := cfg.Templates[name].Description; description != "" {
+			fmt.Printf("%s - %s\n", name, description)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
+
+// streamPrinter echoes raw tokens while a response is streaming and wipes
+// them once it completes so the glamour-rendered version can replace them
+type streamPrinter struct {
+	enabled bool
+	printed strings.Builder
+	// kept is set if the streamed response scrolled past the top of the
+	// terminal, so it could not be wiped and is left in place unrendered
+	kept bool
+}
+
+func newStreamPrinter() *streamPrinter {
+	return &streamPrinter{enabled: utils.IsTerminal(os.Stdout)}
+}
+
+// handler returns the delta callback, or nil when stdout is not a terminal
+func (s *streamPrinter) handler() api.StreamHandler {
+	if !s.enabled {
+		return nil
+	}
+	return func(delta string) {
+		s.printed.WriteString(delta)
+		fmt.Print(delta)
+	}
+}
+
+// finish erases the raw streamed output if it still fits on the screen
+func (s *streamPrinter) finish() {
+	s.kept = false
+	if s.printed.Len() == 0 {
+		return
+	}
+	printed := s.printed.String()
+	s.printed.Reset()
+
+	rows := utils.CountRows(printed, utils.TerminalWidth(os.Stdout))
+	if rows > utils.TerminalHeight(os.Stdout) {
+		s.kept = true
+		if !strings.HasSuffix(printed, "\n") {
+			fmt.Println()
+		}
+		return
+	}
+	utils.ClearRows(os.Stdout, rows)
+}
+
+// attachFiles prepends the files named by paths to the input as fenced blocks
+func attachFiles(input string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return input, nil
+	}
+
+	attachments, err := utils.ReadAttachments(paths)
+	if err != nil {
+		return "", err
+	}
+	if len(attachments) == 0 {
+		return input, nil
+	}
+
+	files := utils.FormatAttachments(attachments)
+	if input == "" {
+		return files, nil
+	}
+	return files + "\n" + input, nil
 }
 
-func handleHistory(args []string) {
+// jsonOutput returns the JSON output requested by --json or --schema, or nil
+func (f callFlags) jsonOutput() *llm.JSONOutput {
+	if !f.JSON {
+		return nil
+	}
+	return &llm.JSONOutput{Schema: f.Schema}
+}
+
+// loadImages reads the images given with --image
+func loadImages(sources []string) ([]api.Image, error) {
+	var images []api.Image
+	for _, source := range sources {
+		image, err := api.NewImage(source)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func handleHistory(args []string, flags callFlags) {
 	if len(args) < 1 {
 		fmt.Println("Error: Assistant name required")
-		fmt.Println("Usage: echo -h <assistant_name> [number_of_messages]")
+		fmt.Println("Usage: llmcli -h <assistant_name> [number_of_messages] [--session <name>] [--tools]")
 		return
 	}
 
 	assistantName := args[0]
 	limit := 10 // default limit
-	if len(args) > 1 {
-		if n, err := strconv.Atoi(args[1]); err == nil && n > 0 {
+	showTools := false
+	for _, arg := range args[1:] {
+		if arg == "--tools" {
+			showTools = true
+		} else if n, err := strconv.Atoi(arg); err == nil && n > 0 {
 			limit = n
 		}
 	}
 
+	if showTools {
+		printToolCalls(assistantName, flags.Session, limit)
+		return
+	}
+	printHistory(assistantName, flags.Session, limit)
+}
+
+// printToolCalls prints the last limit tool calls of an assistant with
+// their output, restricted to one session unless session is empty
+func printToolCalls(assistantName string, session string, limit int) {
 	history, err := utils.NewHistory()
 	if err != nil {
 		fmt.Printf("Error initializing history: %v\n", err)
@@ -174,7 +502,47 @@ func handleHistory(args []string) {
 	}
 	defer history.Close()
 
-	records, err := history.Fetch(assistantName, limit)
+	calls, err := history.FetchToolCalls(assistantName, session, limit)
+	if err != nil {
+		fmt.Printf("Error fetching tool calls: %v\n", err)
+		return
+	}
+	if len(calls) == 0 {
+		fmt.Printf("No tool calls found for assistant '%s'\n", assistantName)
+		return
+	}
+
+	fmt.Printf("\nTool calls of assistant '%s' (last %d):\n", assistantName, limit)
+	fmt.Println("----------------------------------------")
+	for _, call := range calls {
+		fmt.Printf("\033[33m%s [%s]\033[0m \033[36m%s\033[0m %s\n",
+			call.CreatedAt.Local().Format("2006-01-02 15:04"), call.Session, call.Tool, call.Arguments)
+		if call.Error != "" {
+			fmt.Printf("\033[31merror:\033[0m %s\n", call.Error)
+		}
+		if output := strings.TrimRight(call.Output, "\n"); output != "" {
+			fmt.Println(output)
+		}
+		fmt.Println()
+	}
+}
+
+// printHistory prints the last limit messages of an assistant's chat history,
+// restricted to one session unless session is empty
+func printHistory(assistantName string, session string, limit int) {
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	var records []utils.Record
+	if session == "" {
+		records, err = history.Fetch(assistantName, limit)
+	} else {
+		records, err = history.FetchSession(assistantName, session, limit)
+	}
 	if err != nil {
 		fmt.Printf("Error fetching history: %v\n", err)
 		return
@@ -185,16 +553,33 @@ func handleHistory(args []string) {
 		return
 	}
 
-	fmt.Printf("\nChat history for assistant '%s' (last %d messages):\n", assistantName, limit)
+	if session == "" {
+		fmt.Printf("\nChat history for assistant '%s' (last %d messages):\n", assistantName, limit)
+	} else {
+		fmt.Printf("\nChat history for assistant '%s', session '%s' (last %d messages):\n", assistantName, session, limit)
+	}
 	fmt.Println("----------------------------------------")
 
 	// Print in chronological order (oldest first)
+	lastSession := ""
 	for _, record := range records {
+		if session == "" && record.Session != lastSession {
+			fmt.Printf("\033[33m[session: %s]\033[0m\n", record.Session)
+			lastSession = record.Session
+		}
 		roleColor := "\033[36m" // cyan for user
 		if record.Role == "assistant" {
 			roleColor = "\033[32m" // green for assistant
 		}
-		fmt.Printf("%s%s\033[0m: %s\n\n", roleColor, record.Role, record.Content)
+		role := record.Role
+		if record.Model != "" {
+			role += " (" + record.Model + ")"
+		}
+		content := record.Content
+		for _, image := range record.Images {
+			content += fmt.Sprintf("\n[image: %s]", image)
+		}
+		fmt.Printf("%s%s\033[0m: %s\n\n", roleColor, role, strings.TrimPrefix(content, "\n"))
 	}
 }
 
@@ -221,6 +606,217 @@ func handleClearHistory(args []string) {
 	fmt.Printf("Successfully cleared chat history for assistant '%s'\n", assistantName)
 }
 
+func handleListSessions(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Error: Assistant name required")
+		fmt.Println("Usage: llmcli --list-sessions <assistant_name>")
+		return
+	}
+
+	assistantName := args[0]
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	sessions, err := history.ListSessions(assistantName)
+	if err != nil {
+		fmt.Printf("Error listing sessions: %v\n", err)
+		return
+	}
+
+	if len(sessions) == 0 {
+		fmt.Printf("No sessions found for assistant '%s'\n", assistantName)
+		return
+	}
+
+	fmt.Printf("\nSessions for assistant '%s':\n", assistantName)
+	fmt.Println("----------------------------------------")
+	for _, session := range sessions {
+		marker := "  "
+		if session.Current {
+			marker = "\033[32m*\033[0m "
+		}
+		fmt.Printf("%s%-24s %4d messages  last active %s\n", marker, session.Name, session.Messages, session.UpdatedAt)
+	}
+}
+
+func handleListModels(args []string) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+
+	var names []string
+	if len(args) > 0 {
+		names = args
+	} else {
+		for name, model := range cfg.Models {
+			if _, ok := api.Providers[model.API].(api.ModelLister); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No configured models use a provider that can list models")
+		return
+	}
+
+	for _, name := range names {
+		if _, exists := cfg.Models[name]; !exists {
+			fmt.Printf("%s: Error: model not found in config\n", name)
+			continue
+		}
+
+		available, installed, err := llm.ListModels(name)
+		if err != nil {
+			fmt.Printf("%s: Error: %v\n", name, err)
+			continue
+		}
+
+		status := "\033[32minstalled\033[0m"
+		if !installed {
+			status = "\033[31mnot installed\033[0m"
+		}
+		fmt.Printf("%s (%s): %s\n", name, cfg.Models[name].Model, status)
+		for _, model := range available {
+			fmt.Printf("  %s\n", model)
+		}
+	}
+}
+
+func handleConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "show" || len(args) > 2 || len(args) == 2 && args[1] != "--resolved" {
+		fmt.Println("Usage: llmcli config show [--resolved]")
+		return
+	}
+	configPath := config.GetConfigPath()
+
+	if len(args) == 1 {
+		content, err := os.ReadFile(configPath)
+		if err != nil {
+			fmt.Printf("Error reading config: %v\n", err)
+			return
+		}
+		fmt.Print(string(content))
+		return
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	// API keys and headers are masked so the output can be shared
+	resolved := *cfg
+	resolved.Models = make(map[string]config.ModelConfig, len(cfg.Models))
+	for name, model := range cfg.Models {
+		if model.API_KEY != "" {
+			model.API_KEY = "****"
+		}
+		if len(model.Headers) > 0 {
+			headers := make(map[string]string, len(model.Headers))
+			for header := range model.Headers {
+				headers[header] = "****"
+			}
+			model.Headers = headers
+		}
+		resolved.Models[name] = model
+	}
+	data, err := json.MarshalIndent(resolved, "", "    ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+func handleListMCP(args []string) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+
+	names := args
+	if len(names) == 0 {
+		for name := range cfg.MCPServers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("No MCP servers configured")
+		return
+	}
+
+	for _, name := range names {
+		servers, available, err := mcp.StartServers([]string{name}, cfg.MCPServers)
+		if err != nil {
+			fmt.Printf("%s: Error: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%s:\n", name)
+		toolNames := make([]string, 0, len(available))
+		for toolName := range available {
+			toolNames = append(toolNames, toolName)
+		}
+		sort.Strings(toolNames)
+		for _, toolName := range toolNames {
+			tool := available[toolName]
+			confirm := ""
+			if tool.SideEffects() {
+				confirm = " (asks for confirmation)"
+			}
+			fmt.Printf("  %s%s\n", toolName, confirm)
+			if description := tool.Definition().Description; description != "" {
+				fmt.Printf("    %s\n", strings.ReplaceAll(description, "\n", "\n    "))
+			}
+		}
+		servers.Close()
+	}
+}
+
+// switchSession starts or resumes a session as requested by the flags
+func switchSession(assistantName string, flags callFlags) error {
+	if !flags.switchesSession() {
+		return nil
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		return fmt.Errorf("failed to initialize history: %v", err)
+	}
+	defer history.Close()
+
+	if flags.NewSession {
+		name, err := history.NewSession(assistantName, flags.NewSessionName)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Started session '%s' for assistant '%s'\n", name, assistantName)
+		return nil
+	}
+
+	exists, err := history.SessionExists(assistantName, flags.Resume)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("session '%s' not found for assistant '%s'", flags.Resume, assistantName)
+	}
+	if err := history.UseSession(assistantName, flags.Resume); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Resumed session '%s' for assistant '%s'\n", flags.Resume, assistantName)
+	return nil
+}
+
 func debug() {
 	cfg, err := config.GetConfig()
 	if err != nil {
@@ -233,6 +829,9 @@ func debug() {
 	for name, model := range cfg.Models {
 		fmt.Printf("\nModel: %s\n", name)
 		fmt.Printf("  API: %s\n", model.API)
+		if model.BaseURL != "" {
+			fmt.Printf("  BaseURL: %s\n", model.BaseURL)
+		}
 		fmt.Printf("  Model: %s\n", model.Model)
 		fmt.Printf("  API_KEY: %s\n", model.API_KEY)
 
@@ -259,4 +858,125 @@ func debug() {
 			fmt.Printf("  Test response: %s\n", response)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func handleUsage(flags callFlags) {
+	by := flags.By
+	if by == "" {
+		by = "model"
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	summaries, err := history.UsageReport(flags.Since, by)
+	if err != nil {
+		fmt.Printf("Error reading usage: %v\n", err)
+		return
+	}
+
+	period := "all time"
+	if !flags.Since.IsZero() {
+		period = "since " + flags.Since.Format("2006-01-02 15:04")
+	}
+	if len(summaries) == 0 {
+		fmt.Printf("No usage recorded (%s)\n", period)
+		return
+	}
+
+	fmt.Printf("\nUsage by %s (%s):\n", by, period)
+	fmt.Println("----------------------------------------")
+	fmt.Printf("%-24s %6s %12s %12s %10s\n", strings.ToUpper(by), "CALLS", "PROMPT", "COMPLETION", "COST")
+
+	var total utils.UsageSummary
+	for _, summary := range summaries {
+		key := summary.Key
+		if key == "" {
+			key = "(none)" // Direct model calls have no assistant
+		}
+		fmt.Printf("%-24s %6d %12d %12d %10s\n", key, summary.Calls, summary.PromptTokens, summary.CompletionTokens, formatCost(summary.Cost))
+		total.Calls += summary.Calls
+		total.PromptTokens += summary.PromptTokens
+		total.CompletionTokens += summary.CompletionTokens
+		total.Cost += summary.Cost
+	}
+	fmt.Println("----------------------------------------")
+	fmt.Printf("%-24s %6d %12d %12d %10s\n", "TOTAL", total.Calls, total.PromptTokens, total.CompletionTokens, formatCost(total.Cost))
+}
+
+func formatCost(cost float64) string {
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+func handleSearch(args []string, flags callFlags) {
+	opts := utils.SearchOptions{Since: flags.Since, Limit: 20}
+	var terms []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-a", "--assistant", "--role", "-n":
+			if i+1 >= len(args) {
+				fmt.Printf("Error: %s requires a value\n", args[i])
+				return
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--role":
+				if value != "user" && value != "assistant" {
+					fmt.Println("Error: --role must be user or assistant")
+					return
+				}
+				opts.Role = value
+			case "-n":
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					fmt.Println("Error: -n must be a positive number")
+					return
+				}
+				opts.Limit = n
+			default:
+				opts.Assistant = value
+			}
+			i++
+		default:
+			terms = append(terms, args[i])
+		}
+	}
+
+	if len(terms) == 0 {
+		fmt.Println("Error: Search query required")
+		fmt.Println("Usage: llmcli search <query> [--assistant <name>] [--role user|assistant] [--since <date>] [-n <limit>]")
+		return
+	}
+
+	if utils.IsTerminal(os.Stdout) {
+		opts.HighlightStart, opts.HighlightEnd = "\033[1;33m", "\033[0m"
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		fmt.Printf("Error initializing history: %v\n", err)
+		return
+	}
+	defer history.Close()
+
+	query := strings.Join(terms, " ")
+	results, err := history.Search(query, opts)
+	if err != nil {
+		fmt.Printf("Error searching history: %v\n", err)
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No messages found matching '%s'\n", query)
+		return
+	}
+
+	for _, result := range results {
+		fmt.Printf("\033[90m#%d  %s\033[0m  %s [%s] %s\n", result.ID, result.CreatedAt, result.Assistant, result.Session, result.Role)
+		fmt.Printf("    %s\n\n", result.Snippet)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/llm/mcp"
+	"llm_cli/repl"
+	"llm_cli/utils"
+
+	"github.com/chzyer/readline"
+)
+
+const replHistoryFile = "repl_history"
+
+// handleInteractive starts the interactive chat mode
+func handleInteractive(args []string, flags callFlags) {
+	assistantName := ""
+	if len(args) > 0 {
+		if args[0] != "-a" && args[0] != "--assistant" || len(args) < 2 {
+			fmt.Println("Usage: llmcli -i [-a <assistant_name>]")
+			return
+		}
+		assistantName = args[1]
+	}
+
+	if assistantName == "" {
+		name, err := llm.DefaultAssistant()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		assistantName = name
+	} else if !repl.AssistantExists(assistantName) {
+		fmt.Printf("Error: assistant '%s' not found in config\n", assistantName)
+		return
+	}
+
+	if err := switchSession(assistantName, flags); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          repl.Prompt(assistantName),
+		HistoryFile:     filepath.Join(filepath.Dir(config.GetConfigPath()), replHistoryFile),
+		InterruptPrompt: "^C",
+		EOFPrompt:       "/exit",
+	})
+	if err != nil {
+		fmt.Printf("Error initializing line editor: %v\n", err)
+		return
+	}
+	defer rl.Close()
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Printf("Error getting config: %v\n", err)
+		return
+	}
+	// MCP servers keep running, with their state, for the whole session
+	servers := mcp.NewServers(cfg.MCPServers)
+	defer servers.Close()
+
+	session := &repl.Session{
+		Assistant:    assistantName,
+		Session:      flags.Session,
+		Out:          os.Stdout,
+		PrintHistory: printHistory,
+		ListSessions: func(assistant string) { handleListSessions([]string{assistant}) },
+	}
+	session.Chat = func(input string) (string, error) {
+		return replChat(session, input, flags, servers)
+	}
+	fmt.Printf("Chatting with assistant '%s'. Type /help for commands.\n", assistantName)
+	session.Run(rl)
+}
+
+// replChat sends a message of the interactive session, streaming and then
+// rendering the reply
+func replChat(session *repl.Session, input string, flags callFlags, servers *mcp.Servers) (string, error) {
+	stream := newStreamPrinter()
+	response, err := llm.AssistantCallWithOptions(session.Assistant, input, llm.AssistantOptions{
+		Model:       session.Model,
+		Session:     session.Session,
+		Params:      flags.Params,
+		OnDelta:     stream.handler(),
+		Confirm:     utils.Confirm,
+		OnToolCalls: stream.finish,
+		Vars:        llm.PromptVars{Vars: flags.Vars},
+		MCPServers:  servers,
+	})
+	stream.finish()
+	if err != nil {
+		return "", err
+	}
+
+	if !stream.kept {
+		renderResponse(response)
+	}
+	return response, nil
+}
@@ -0,0 +1,55 @@
+// Package repl is the interactive chat mode. It assembles messages from
+// lines of input and runs slash commands, independently of the line editor
+// and of how replies are produced and shown.
+package repl
+
+import "strings"
+
+// continuationPrompt is shown while a message spans several lines
+const continuationPrompt = "... "
+
+// LineReader reads lines of input, such as a *readline.Instance
+type LineReader interface {
+	Readline() (string, error)
+	SetPrompt(prompt string)
+}
+
+// ReadInput reads one message from r, joining continuation lines ending in
+// a backslash and blocks wrapped in """ lines. prompt is shown for the first
+// line and restored afterwards.
+func ReadInput(r LineReader, prompt string) (string, error) {
+	r.SetPrompt(prompt)
+	defer r.SetPrompt(prompt)
+
+	line, err := r.Readline()
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(line) == `"""` {
+		var lines []string
+		r.SetPrompt(continuationPrompt)
+		for {
+			line, err := r.Readline()
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(line) == `"""` {
+				return strings.Join(lines, "\n"), nil
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	var lines []string
+	for strings.HasSuffix(line, `\`) {
+		lines = append(lines, strings.TrimSuffix(line, `\`))
+		r.SetPrompt(continuationPrompt)
+		line, err = r.Readline()
+		if err != nil {
+			return "", err
+		}
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,319 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"llm_cli/config"
+	"llm_cli/utils"
+
+	"github.com/chzyer/readline"
+)
+
+// Help lists the commands of the interactive mode
+const Help = `Commands:
+  /assistant [name]  - Show or switch the current assistant
+  /model [name]      - Show or override the model for this session ("/model -" resets)
+  /session [name]    - List sessions, or switch to (and create) a named session
+  /new [name]        - Start a new session for the current assistant
+  /history [n]       - Show the last n messages of the current session
+  /clear             - Clear the messages of the current session
+  /save [file]       - Save this session's transcript as Markdown
+  /help              - Show this help
+  /exit              - Leave interactive mode
+
+End a line with \ to continue on the next line, or wrap a block in """ lines.`
+
+// Turn is one exchange of the interactive session, kept for /save
+type Turn struct {
+	Assistant string
+	Input     string
+	Response  string
+}
+
+// Session holds the state of an interactive chat
+type Session struct {
+	Assistant string
+	// Model overrides the model of the assistant if set
+	Model string
+	// Session is the history session of the next turn; empty means the
+	// assistant's current session
+	Session string
+	Turns   []Turn
+	// Out receives the output of commands
+	Out io.Writer
+
+	// Chat sends a message to the current assistant, shows the reply and
+	// returns it
+	Chat func(input string) (string, error)
+	// PrintHistory shows the last limit messages of a session
+	PrintHistory func(assistant, session string, limit int)
+	// ListSessions shows the sessions of an assistant
+	ListSessions func(assistant string)
+}
+
+// Run reads and handles messages from r until the input ends or /exit
+func (s *Session) Run(r LineReader) {
+	for {
+		input, err := ReadInput(r, Prompt(s.Assistant))
+		if err == io.EOF {
+			return
+		}
+		if err == readline.ErrInterrupt {
+			continue
+		}
+		if err != nil {
+			s.errorf("Error reading input: %v", err)
+			return
+		}
+		if !s.Handle(input) {
+			return
+		}
+	}
+}
+
+// Handle runs a slash command or sends a message to the assistant and
+// reports whether the session should continue
+func (s *Session) Handle(input string) bool {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return true
+	}
+	if strings.HasPrefix(input, "/") {
+		return s.command(input)
+	}
+
+	response, err := s.Chat(input)
+	if err != nil {
+		s.errorf("Error: %v", err)
+		return true
+	}
+	s.Turns = append(s.Turns, Turn{Assistant: s.Assistant, Input: input, Response: response})
+	return true
+}
+
+// command runs a slash command and reports whether the session should continue
+func (s *Session) command(line string) bool {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "/exit", "/quit":
+		return false
+	case "/help":
+		fmt.Fprintln(s.Out, Help)
+	case "/assistant":
+		s.assistantCommand(args)
+	case "/model":
+		s.modelCommand(args)
+	case "/history":
+		limit := 10
+		if len(args) > 0 {
+			if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+				limit = n
+			}
+		}
+		session, err := s.currentSession()
+		if err != nil {
+			s.errorf("Error: %v", err)
+			return true
+		}
+		s.PrintHistory(s.Assistant, session, limit)
+	case "/session":
+		s.sessionCommand(args)
+	case "/new":
+		s.newCommand(args)
+	case "/clear":
+		s.clearCommand()
+	case "/save":
+		path := fmt.Sprintf("llmcli-%s.md", time.Now().Format("20060102-150405"))
+		if len(args) > 0 {
+			path = args[0]
+		}
+		if err := s.save(path); err != nil {
+			s.errorf("Error saving transcript: %v", err)
+			return true
+		}
+		fmt.Fprintf(s.Out, "Transcript saved to %s\n", path)
+	default:
+		s.errorf("Unknown command %s, type /help for a list of commands", name)
+	}
+	return true
+}
+
+func (s *Session) assistantCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(s.Out, "Current assistant: %s\n", s.Assistant)
+		fmt.Fprintf(s.Out, "Available assistants: %s\n", strings.Join(AssistantNames(), ", "))
+		return
+	}
+	if !AssistantExists(args[0]) {
+		s.errorf("Assistant '%s' not found in config", args[0])
+		return
+	}
+	s.Assistant = args[0]
+	s.Session = ""
+	fmt.Fprintf(s.Out, "Switched to assistant '%s'\n", s.Assistant)
+}
+
+func (s *Session) modelCommand(args []string) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		s.errorf("Error getting config: %v", err)
+		return
+	}
+
+	if len(args) == 0 {
+		current := s.Model
+		if current == "" {
+			current = cfg.Assistants[s.Assistant].Model + " (assistant default)"
+		}
+		fmt.Fprintf(s.Out, "Current model: %s\n", current)
+		return
+	}
+
+	if args[0] == "-" {
+		s.Model = ""
+		fmt.Fprintln(s.Out, "Model override cleared")
+		return
+	}
+
+	if _, exists := cfg.Models[args[0]]; !exists {
+		s.errorf("Model '%s' not found in config", args[0])
+		return
+	}
+	s.Model = args[0]
+	fmt.Fprintf(s.Out, "Using model '%s' for this session\n", s.Model)
+}
+
+// currentSession returns the session the next turn will be stored in
+func (s *Session) currentSession() (string, error) {
+	if s.Session != "" {
+		return s.Session, nil
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize history: %v", err)
+	}
+	defer history.Close()
+	return history.CurrentSession(s.Assistant)
+}
+
+func (s *Session) sessionCommand(args []string) {
+	if len(args) == 0 {
+		s.ListSessions(s.Assistant)
+		return
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		s.errorf("Error initializing history: %v", err)
+		return
+	}
+	defer history.Close()
+
+	if err := history.UseSession(s.Assistant, args[0]); err != nil {
+		s.errorf("Error: %v", err)
+		return
+	}
+	s.Session = ""
+	fmt.Fprintf(s.Out, "Switched to session '%s'\n", args[0])
+}
+
+func (s *Session) newCommand(args []string) {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		s.errorf("Error initializing history: %v", err)
+		return
+	}
+	defer history.Close()
+
+	name, err = history.NewSession(s.Assistant, name)
+	if err != nil {
+		s.errorf("Error: %v", err)
+		return
+	}
+	s.Session = ""
+	fmt.Fprintf(s.Out, "Started session '%s' for assistant '%s'\n", name, s.Assistant)
+}
+
+func (s *Session) clearCommand() {
+	session, err := s.currentSession()
+	if err != nil {
+		s.errorf("Error: %v", err)
+		return
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		s.errorf("Error initializing history: %v", err)
+		return
+	}
+	defer history.Close()
+
+	if err := history.ClearSession(s.Assistant, session); err != nil {
+		s.errorf("Error clearing session: %v", err)
+		return
+	}
+	fmt.Fprintf(s.Out, "Cleared session '%s' of assistant '%s'\n", session, s.Assistant)
+}
+
+// save writes the turns of this session to path as Markdown
+func (s *Session) save(path string) error {
+	if len(s.Turns) == 0 {
+		return fmt.Errorf("nothing to save yet")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# llmcli session %s\n\n", time.Now().Format("2006-01-02 15:04"))
+	for _, turn := range s.Turns {
+		fmt.Fprintf(&b, "## user → %s\n\n%s\n\n", turn.Assistant, turn.Input)
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", turn.Assistant, turn.Response)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// errorf prints an error message in red
+func (s *Session) errorf(format string, a ...interface{}) {
+	fmt.Fprintf(s.Out, utils.RedColor+format+utils.ResetColor+"\n", a...)
+}
+
+// Prompt returns the prompt for an assistant
+func Prompt(assistantName string) string {
+	return fmt.Sprintf("\033[36m%s\033[0m> ", assistantName)
+}
+
+// AssistantExists reports whether the config has the assistant
+func AssistantExists(name string) bool {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return false
+	}
+	_, exists := cfg.Assistants[name]
+	return exists
+}
+
+// AssistantNames returns the names of the assistants in the config, sorted
+func AssistantNames() []string {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Assistants))
+	for name := range cfg.Assistants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/utils"
+)
+
+func TestAttachments(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "attach_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		".gitignore":           "vendor/\n*.gen.go\n!keep.gen.go\n",
+		"main.go":              "package main\n",
+		"src/util/util.go":     "package util\n",
+		"src/util/util.gen.go": "package util // generated\n",
+		"src/util/keep.gen.go": "package util // kept\n",
+		"vendor/dep/dep.go":    "package dep\n",
+		"README.md":            "# Title\n\n```go\ncode\n```\n",
+		"logo.png":             "\x89PNG\x00\x00",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	os.Mkdir(filepath.Join(tmpDir, ".git"), 0755)
+
+	originalDir, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(originalDir)
+
+	paths := func(attachments []utils.Attachment) []string {
+		var names []string
+		for _, attachment := range attachments {
+			names = append(names, attachment.Path)
+		}
+		return names
+	}
+
+	t.Run("Glob Respects Gitignore", func(t *testing.T) {
+		attachments, err := utils.ReadAttachments([]string{"**/*.go"})
+		if err != nil {
+			t.Fatalf("ReadAttachments failed: %v", err)
+		}
+		got := strings.Join(paths(attachments), ",")
+		if got != "main.go,src/util/keep.gen.go,src/util/util.go" {
+			t.Errorf("Unexpected files: %s", got)
+		}
+	})
+
+	t.Run("Explicit Files And Binaries", func(t *testing.T) {
+		attachments, err := utils.ReadAttachments([]string{"vendor/dep/dep.go", "logo.png", "main.go", "main.go"})
+		if err != nil {
+			t.Fatalf("ReadAttachments failed: %v", err)
+		}
+		got := strings.Join(paths(attachments), ",")
+		if got != "vendor/dep/dep.go,main.go" {
+			t.Errorf("Expected explicit ignored file attached once and binary skipped, got %s", got)
+		}
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		if _, err := utils.ReadAttachments([]string{"missing.go"}); err == nil {
+			t.Error("Expected error for missing file")
+		}
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		attachments, err := utils.ReadAttachments([]string{"README.md"})
+		if err != nil {
+			t.Fatalf("ReadAttachments failed: %v", err)
+		}
+		formatted := utils.FormatAttachments(attachments)
+		if !strings.HasPrefix(formatted, "````README.md\n") || !strings.HasSuffix(formatted, "\n````\n") {
+			t.Errorf("Expected a longer fence labelled with the path, got %q", formatted)
+		}
+	})
+}
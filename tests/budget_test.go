@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+)
+
+func TestBudgets(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "budget_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	// Every call reports 100 tokens
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		return http.StatusOK, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":60,"completion_tokens":40}}`
+	})
+	defer server.Close()
+
+	model := func(budget *config.Budget, fallback ...string) config.ModelConfig {
+		return config.ModelConfig{API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, Budget: budget, Fallback: fallback}
+	}
+	daily := func(tokens int, policy string) *config.Budget {
+		return &config.Budget{Daily: &config.BudgetLimit{Tokens: tokens}, Policy: policy}
+	}
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"limited":   model(daily(150, "")),
+			"lenient":   model(daily(150, config.BudgetPolicyWarn)),
+			"chained":   model(daily(50, ""), "spare"),
+			"spare":     model(nil),
+			"invalid":   model(daily(150, "sometimes")),
+			"unlimited": model(nil),
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"frugal": {Model: "unlimited", Prompt: "Be brief.", ChatContextWindow: 5, Budget: daily(150, "")},
+		},
+	})
+
+	t.Run("Refuse", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := llm.SimpleCall("limited", "hi"); err != nil {
+				t.Fatalf("Call %d within budget failed: %v", i+1, err)
+			}
+		}
+		_, err := llm.SimpleCall("limited", "hi")
+		if err == nil || !strings.Contains(err.Error(), "budget exceeded: daily budget of model 'limited'") {
+			t.Errorf("Expected budget error, got %v", err)
+		}
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if _, err := llm.SimpleCall("lenient", "hi"); err != nil {
+				t.Fatalf("Call %d with warn policy failed: %v", i+1, err)
+			}
+		}
+	})
+
+	t.Run("Over Budget Falls Back", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := llm.SimpleCall("chained", "hi"); err != nil {
+				t.Fatalf("Call %d failed: %v", i+1, err)
+			}
+		}
+	})
+
+	t.Run("Invalid Policy", func(t *testing.T) {
+		if _, err := llm.SimpleCall("invalid", "hi"); err == nil {
+			t.Error("Expected error for invalid budget policy")
+		}
+	})
+
+	t.Run("Assistant", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := llm.AssistantCall("frugal", "hi"); err != nil {
+				t.Fatalf("Call %d within budget failed: %v", i+1, err)
+			}
+		}
+		_, err := llm.AssistantCall("frugal", "hi")
+		if err == nil || !strings.Contains(err.Error(), "assistant 'frugal'") {
+			t.Errorf("Expected assistant budget error, got %v", err)
+		}
+	})
+}
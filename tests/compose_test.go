@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"testing"
+
+	"llm_cli/llm"
+)
+
+func TestComposeInput(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		instruction string
+		document    string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "instruction only",
+			instruction: "explain channels",
+			want:        "explain channels",
+		},
+		{
+			name:     "document only",
+			document: "package main\n",
+			want:     "package main",
+		},
+		{
+			name:        "default template",
+			instruction: "focus on error handling",
+			document:    "package main\n",
+			want:        "focus on error handling\n\n<document>\npackage main\n</document>",
+		},
+		{
+			name:        "custom template",
+			template:    "Review this file:\n{{.Input}}\nFocus: {{.Instruction}}",
+			instruction: "naming",
+			document:    "x := 1",
+			want:        "Review this file:\nx := 1\nFocus: naming",
+		},
+		{
+			name:        "invalid template",
+			template:    "{{.Missing}}",
+			instruction: "a",
+			document:    "b",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := llm.ComposeInput(tt.template, tt.instruction, tt.document)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ComposeInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ComposeInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+)
+
+// writeFiles writes files relative to dir, creating their directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json": `{
+			"default": "coder",
+			"models": {"gpt": {"API": "OpenAI", "Model": "gpt-4o"}},
+			"assistants": {
+				"coder": {"model": "gpt", "prompt": "json prompt", "chatContextWindow": 3, "tools": ["grep"]},
+				"plain": {"model": "gpt", "prompt": "unchanged"}
+			},
+			"templates": {"commit": {"template": "json template", "assistant": "coder"}}
+		}`,
+		"assistants/coder.yaml": "chatContextWindow: 8\ntemperature: 0.2\ntools: [read_file, list_directory]\n",
+		"assistants/coder.md":   "---\nmaxTokens: 500\n---\n\nYou are a {{.lang}} expert.\n\nBe brief.\n",
+		"assistants/writer.md":  "You write docs.\n",
+		"assistants/notes.txt":  "ignored",
+		"prompts/commit.md":     "---\ndescription: Write a commit message\n---\nSummarize:\n{{.Stdin}}\n",
+		"prompts/review.md":     "Review this.",
+	})
+
+	cfg, err := config.LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	t.Run("Files Override Config Fields", func(t *testing.T) {
+		coder := cfg.Assistants["coder"]
+		if coder.Model != "gpt" {
+			t.Errorf("Expected model from config.json to be kept, got %q", coder.Model)
+		}
+		if coder.ChatContextWindow != 8 || coder.Temperature == nil || *coder.Temperature != 0.2 {
+			t.Errorf("Expected settings from coder.yaml, got %+v", coder)
+		}
+		if strings.Join(coder.Tools, ",") != "read_file,list_directory" {
+			t.Errorf("Expected tools replaced by coder.yaml, got %v", coder.Tools)
+		}
+		if coder.MaxTokens == nil || *coder.MaxTokens != 500 {
+			t.Errorf("Expected maxTokens from the front matter of coder.md, got %v", coder.MaxTokens)
+		}
+		if coder.Prompt != "You are a {{.lang}} expert.\n\nBe brief." {
+			t.Errorf("Expected prompt from the body of coder.md, got %q", coder.Prompt)
+		}
+	})
+
+	t.Run("New And Untouched Assistants", func(t *testing.T) {
+		if cfg.Assistants["writer"].Prompt != "You write docs." {
+			t.Errorf("Expected assistant defined by writer.md, got %+v", cfg.Assistants["writer"])
+		}
+		if cfg.Assistants["plain"].Prompt != "unchanged" {
+			t.Errorf("Expected assistant without files to be unchanged, got %+v", cfg.Assistants["plain"])
+		}
+		if _, exists := cfg.Assistants["notes"]; exists {
+			t.Error("Expected files with other extensions to be ignored")
+		}
+	})
+
+	t.Run("Prompt Files", func(t *testing.T) {
+		commit := cfg.Templates["commit"]
+		if commit.Template != "Summarize:\n{{.Stdin}}" || commit.Description != "Write a commit message" || commit.Assistant != "coder" {
+			t.Errorf("Expected commit.md merged into the template, got %+v", commit)
+		}
+		if cfg.Templates["review"].Template != "Review this." {
+			t.Errorf("Expected template defined by review.md, got %+v", cfg.Templates["review"])
+		}
+	})
+
+	t.Run("Unknown Setting", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"config.json":          `{}`,
+			"assistants/typo.yaml": "modle: gpt\n",
+		})
+		_, err := config.LoadConfig(filepath.Join(dir, "config.json"))
+		if err == nil || !strings.Contains(err.Error(), "typo.yaml") || !strings.Contains(err.Error(), "modle") {
+			t.Errorf("Expected error naming the file and the unknown setting, got %v", err)
+		}
+	})
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"config.json":       `{}`,
+			"assistants/bad.md": "---\nmodel: [gpt\n---\nHi",
+		})
+		if _, err := config.LoadConfig(filepath.Join(dir, "config.json")); err == nil || !strings.Contains(err.Error(), "bad.md") {
+			t.Errorf("Expected error naming the file, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/utils"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 4},
+		{"hi, there!", 5},
+		{"你好", 2},
+	}
+	for _, tt := range tests {
+		if got := llm.EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenContextWindow(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "context_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	// Answers with the roles of the messages it received; the model "down"
+	// is unavailable
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		if req.Model == "down" {
+			return http.StatusServiceUnavailable, ""
+		}
+		var roles []string
+		for _, message := range req.Messages {
+			roles = append(roles, message.Role)
+		}
+		return http.StatusOK, chatReply(strings.Join(roles, ","))
+	})
+	defer server.Close()
+
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"small": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, ContextTokens: 100},
+			"large": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, ContextTokens: 100000},
+			"plain": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL},
+			"small-then-large": {API: "OpenAICompatible", Model: "down", BaseURL: server.URL, MaxAttempts: 1,
+				ContextTokens: 100, Fallback: []string{"large"}},
+			"large-then-plain": {API: "OpenAICompatible", Model: "down", BaseURL: server.URL, MaxAttempts: 1,
+				ContextTokens: 100000, Fallback: []string{"plain"}},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"reader": {Model: "small", Prompt: "Be brief."},
+		},
+	})
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	// Each turn is about 50 tokens, so only the most recent turn fits
+	long := strings.Repeat("word ", 40)
+	for i := 0; i < 5; i++ {
+		history.Push("reader", "user", long)
+		history.Push("reader", "assistant", "ok")
+	}
+
+	t.Run("Keeps Recent Turns", func(t *testing.T) {
+		response, err := llm.AssistantCall("reader", "hi")
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if response != "system,user,assistant,user" {
+			t.Errorf("Expected system prompt, last turn and input, got %q", response)
+		}
+	})
+
+	// call sends "hi" as reader with a model and chat window and returns the
+	// number of history messages the answering model received
+	call := func(t *testing.T, model string, window int) int {
+		t.Helper()
+		cfg, _ := config.GetConfig()
+		cfg.Assistants["windowed"] = config.AssistantConfig{Model: model, Prompt: "Be brief.", ChatContextWindow: window}
+		defer delete(cfg.Assistants, "windowed")
+		for i := 0; i < 5; i++ {
+			history.Push("windowed", "user", long)
+			history.Push("windowed", "assistant", "ok")
+		}
+		defer history.Clear("windowed")
+
+		response, err := llm.AssistantCall("windowed", "hi")
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		return len(strings.Split(response, ",")) - 2 // Without the system prompt and input
+	}
+
+	t.Run("Context Tokens Govern The Window", func(t *testing.T) {
+		if got := call(t, "large", 1); got != 10 {
+			t.Errorf("Expected all 10 history messages to fit in a large context despite chatContextWindow 1, got %d", got)
+		}
+		if got := call(t, "plain", 1); got != 2 {
+			t.Errorf("Expected chatContextWindow to apply without contextTokens, got %d", got)
+		}
+	})
+
+	t.Run("Fitted To The Answering Model", func(t *testing.T) {
+		if got := call(t, "small-then-large", 1); got != 10 {
+			t.Errorf("Expected the larger context of the fallback model to be used, got %d", got)
+		}
+		if got := call(t, "large-then-plain", 2); got != 4 {
+			t.Errorf("Expected the chat window of the fallback model without contextTokens, got %d", got)
+		}
+	})
+
+	t.Run("Input Too Long", func(t *testing.T) {
+		_, err := llm.AssistantCall("reader", strings.Repeat("word ", 200))
+		if err == nil || !strings.Contains(err.Error(), "context") {
+			t.Errorf("Expected context error, got %v", err)
+		}
+	})
+}
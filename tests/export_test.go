@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"llm_cli/utils"
+)
+
+func TestExportImport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "export_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	created := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	dump := []utils.ExportRecord{
+		{Assistant: "coding", Session: "review", Role: "user", Content: "look at this", CreatedAt: created},
+		{Assistant: "coding", Session: "review", Role: "assistant", Content: "looks good", Model: "gpt4", CreatedAt: created.Add(time.Minute)},
+		{Assistant: "writing", Role: "user", Content: "a title please", CreatedAt: created},
+	}
+
+	t.Run("Import", func(t *testing.T) {
+		imported, err := history.ImportRecords(dump)
+		if err != nil {
+			t.Fatalf("ImportRecords failed: %v", err)
+		}
+		if imported != 3 {
+			t.Errorf("Expected 3 records imported, got %d", imported)
+		}
+
+		exists, err := history.SessionExists("coding", "review")
+		if err != nil || !exists {
+			t.Errorf("Expected session 'review' to be created, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("Import Twice", func(t *testing.T) {
+		imported, err := history.ImportRecords(dump)
+		if err != nil {
+			t.Fatalf("ImportRecords failed: %v", err)
+		}
+		if imported != 0 {
+			t.Errorf("Expected duplicates to be skipped, got %d imported", imported)
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		records, err := history.ExportRecords("coding", "")
+		if err != nil {
+			t.Fatalf("ExportRecords failed: %v", err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records of 'coding', got %d", len(records))
+		}
+		if !records[0].CreatedAt.Equal(created) || records[1].Model != "gpt4" {
+			t.Errorf("Expected timestamps and models to survive the round trip, got %+v", records)
+		}
+
+		records, err = history.ExportRecords("", utils.DefaultSession)
+		if err != nil {
+			t.Fatalf("ExportRecords failed: %v", err)
+		}
+		if len(records) != 1 || records[0].Assistant != "writing" {
+			t.Errorf("Expected the record without session in the default session, got %+v", records)
+		}
+	})
+
+	t.Run("Invalid Record", func(t *testing.T) {
+		if _, err := history.ImportRecords([]utils.ExportRecord{{Content: "orphan"}}); err == nil {
+			t.Error("Expected error for record without assistant and role")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/utils"
+)
+
+// newStatusServer answers every chat completion with the given status, or
+// with content when the status is 200
+func newStatusServer(t *testing.T, status int, content string) *httptest.Server {
+	return newChatServer(t, func(req chatRequest) (int, string) {
+		if status != http.StatusOK {
+			return status, chatError(fmt.Sprintf("status %d", status))
+		}
+		return status, chatReply(content)
+	})
+}
+
+func TestModelFallback(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "fallback_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	down := newStatusServer(t, http.StatusServiceUnavailable, "")
+	defer down.Close()
+	rejected := newStatusServer(t, http.StatusBadRequest, "")
+	defer rejected.Close()
+	backup := newStatusServer(t, http.StatusOK, "from backup")
+	defer backup.Close()
+	// recovering fails once, then answers
+	recoveringCalls := 0
+	recovering := newChatServer(t, func(req chatRequest) (int, string) {
+		recoveringCalls++
+		if recoveringCalls == 1 {
+			return http.StatusServiceUnavailable, chatError("status 503")
+		}
+		return http.StatusOK, chatReply("from recovering")
+	})
+	defer recovering.Close()
+	toolBackup := newToolServer(t, "read_file", `{"path":"notes.txt"}`)
+	defer toolBackup.Close()
+	os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("buy milk"), 0644)
+	chdir(t, tmpDir)
+
+	model := func(url string, fallback ...string) config.ModelConfig {
+		return config.ModelConfig{API: "OpenAICompatible", Model: "mock", BaseURL: url, MaxAttempts: 1, Fallback: fallback}
+	}
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"primary":  model(down.URL, "backup"),
+			"invalid":  model(rejected.URL, "backup"),
+			"backup":   model(backup.URL),
+			"no-chain": model(down.URL),
+			"misconfigured": func() config.ModelConfig {
+				m := model(backup.URL, "backup")
+				m.Budget = &config.Budget{Daily: &config.BudgetLimit{Tokens: 1000}, Policy: "bogus"}
+				return m
+			}(),
+			"recovering":  model(recovering.URL, "tool-backup"),
+			"tool-backup": model(toolBackup.URL),
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"helper": {Model: "primary", Prompt: "You are helpful.", ChatContextWindow: 5},
+			"agent":  {Model: "recovering", Prompt: "Use tools.", Tools: []string{"read_file"}},
+		},
+	})
+
+	t.Run("Retryable Failure Falls Back", func(t *testing.T) {
+		response, err := llm.SimpleCall("primary", "hi")
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response != "from backup" {
+			t.Errorf("Expected 'from backup', got '%s'", response)
+		}
+	})
+
+	t.Run("Client Error Does Not Fall Back", func(t *testing.T) {
+		if _, err := llm.SimpleCall("invalid", "hi"); err == nil {
+			t.Error("Expected error for non-retryable failure")
+		}
+	})
+
+	t.Run("No Fallback Configured", func(t *testing.T) {
+		if _, err := llm.SimpleCall("no-chain", "hi"); err == nil {
+			t.Error("Expected error without fallback")
+		}
+	})
+
+	t.Run("Budget Errors Other Than Exhaustion Do Not Fall Back", func(t *testing.T) {
+		_, err := llm.SimpleCall("misconfigured", "hi")
+		if err == nil || !strings.Contains(err.Error(), "invalid budget policy") {
+			t.Errorf("Expected the invalid policy to be reported, got %v", err)
+		}
+	})
+
+	t.Run("Tool Rounds Stay With The Answering Model", func(t *testing.T) {
+		response, err := llm.AssistantCall("agent", "what is in my notes?")
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if response != "tool said: buy milk" {
+			t.Errorf("Expected the fallback model to answer after the tool call, got %q", response)
+		}
+		if recoveringCalls != 1 {
+			t.Errorf("Expected the primary model to be tried once, got %d calls", recoveringCalls)
+		}
+	})
+
+	t.Run("History Records Answering Model", func(t *testing.T) {
+		if _, err := llm.AssistantCall("helper", "hi"); err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+
+		history, err := utils.NewHistory()
+		if err != nil {
+			t.Fatalf("Failed to create history: %v", err)
+		}
+		defer history.Close()
+
+		records, err := history.Fetch("helper", 2)
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if len(records) != 2 || records[1].Model != "backup" {
+			t.Errorf("Expected assistant response recorded with model 'backup', got %+v", records)
+		}
+	})
+}
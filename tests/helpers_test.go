@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// chatRequest is a request received by a newChatServer
+type chatRequest struct {
+	Path   string      `json:"-"`
+	Query  url.Values  `json:"-"`
+	Header http.Header `json:"-"`
+	// Body is the whole request, for checks of fields not decoded below
+	Body map[string]interface{} `json:"-"`
+
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+
+	// Reply holds headers to send with the response
+	Reply http.Header `json:"-"`
+}
+
+// chatMessage is a message of a chatRequest. Content is empty if the
+// message has content parts, such as images; Raw is the message as sent.
+type chatMessage struct {
+	Role       string
+	Content    string
+	ToolCallID string
+	Raw        json.RawMessage
+}
+
+func (m *chatMessage) UnmarshalJSON(data []byte) error {
+	var message struct {
+		Role       string          `json:"role"`
+		Content    json.RawMessage `json:"content"`
+		ToolCallID string          `json:"tool_call_id"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	json.Unmarshal(message.Content, &m.Content)
+	m.Role, m.ToolCallID, m.Raw = message.Role, message.ToolCallID, append(json.RawMessage{}, data...)
+	return nil
+}
+
+// LastMessage returns the last message of the request
+func (r chatRequest) LastMessage() chatMessage {
+	if len(r.Messages) == 0 {
+		return chatMessage{}
+	}
+	return r.Messages[len(r.Messages)-1]
+}
+
+// newChatServer returns a server speaking the OpenAI chat completions API
+// that answers every request with the status and body returned by handler.
+// Streamed requests are answered as an event stream.
+func newChatServer(t *testing.T, handler func(req chatRequest) (status int, body string)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := chatRequest{Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Reply: w.Header()}
+		data, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(data, &req)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &req.Body)
+		}
+		if err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		status, body := handler(req)
+		if req.Stream && status == http.StatusOK {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+// chatReply is the body of a chat completion answering content
+func chatReply(content string) string {
+	return fmt.Sprintf(`{"choices":[{"message":{"content":%q}}]}`, content)
+}
+
+// chatError is the body of an API error
+func chatError(message string) string {
+	return fmt.Sprintf(`{"error":{"message":%q}}`, message)
+}
+
+// chatToolCall is the body of a chat completion calling one tool
+func chatToolCall(name, arguments string) string {
+	return fmt.Sprintf(`{"choices":[{"message":{"content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":%q,"arguments":%q}}]}}]}`, name, arguments)
+}
+
+// chatEvents is an event stream of the given chunks, ended by [DONE]
+func chatEvents(chunks ...string) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		fmt.Fprintf(&b, "data: %s\n\n", chunk)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+// chatDelta is a chunk of a streamed chat completion carrying content
+func chatDelta(content string) string {
+	return fmt.Sprintf(`{"choices":[{"delta":{"content":%q}}]}`, content)
+}
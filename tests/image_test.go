@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+)
+
+// pngHeader is enough of a PNG file for content type detection
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	imagePath := filepath.Join(tmpDir, "cat.png")
+	if err := os.WriteFile(imagePath, pngHeader, 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	t.Run("NewImage", func(t *testing.T) {
+		image, err := api.NewImage(imagePath)
+		if err != nil {
+			t.Fatalf("NewImage failed: %v", err)
+		}
+		if !strings.HasPrefix(image.URL, "data:image/png;base64,") {
+			t.Errorf("Expected a PNG data URL, got %q", image.URL)
+		}
+		if image.Source != imagePath {
+			t.Errorf("Expected source %q, got %q", imagePath, image.Source)
+		}
+
+		url := "https://example.com/cat.png"
+		if image, err := api.NewImage(url); err != nil || image.URL != url {
+			t.Errorf("Expected URL to pass through, got %+v, %v", image, err)
+		}
+
+		textPath := filepath.Join(tmpDir, "notes.txt")
+		os.WriteFile(textPath, []byte("not an image"), 0644)
+		if _, err := api.NewImage(textPath); err == nil {
+			t.Error("Expected error for a non-image file")
+		}
+	})
+
+	image, err := api.NewImage(imagePath)
+	if err != nil {
+		t.Fatalf("NewImage failed: %v", err)
+	}
+	messages := []api.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "what is this?", Images: []api.Image{image}},
+	}
+
+	t.Run("OpenAI Format", func(t *testing.T) {
+		var sent []chatMessage
+		server := newChatServer(t, func(req chatRequest) (int, string) {
+			sent = req.Messages
+			return http.StatusOK, chatReply("a cat")
+		})
+		defer server.Close()
+
+		_, err := api.Providers["OpenAICompatible"].Call(api.Request{
+			Model:    "vision",
+			Messages: messages,
+			BaseURL:  server.URL,
+		})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if len(sent) != 2 || string(sent[0].Raw) != `{"role":"system","content":"be brief"}` {
+			t.Fatalf("Expected plain system message, got %+v", sent)
+		}
+
+		var user struct {
+			Content []api.ContentPart `json:"content"`
+		}
+		if err := json.Unmarshal(sent[1].Raw, &user); err != nil {
+			t.Fatalf("Expected content parts, got %s", sent[1].Raw)
+		}
+		if len(user.Content) != 2 || user.Content[0].Text != "what is this?" ||
+			user.Content[1].Type != "image_url" || user.Content[1].ImageURL.URL != image.URL {
+			t.Errorf("Unexpected content parts: %s", sent[1].Raw)
+		}
+	})
+
+	t.Run("Unsupported Provider", func(t *testing.T) {
+		_, err := api.Providers["Ollama"].Call(api.Request{Model: "llama3", Messages: messages})
+		if err == nil || !strings.Contains(err.Error(), "does not support image input") {
+			t.Errorf("Expected unsupported image error, got %v", err)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		originalHome := os.Getenv("HOME")
+		os.Setenv("HOME", tmpDir)
+		defer os.Setenv("HOME", originalHome)
+
+		history, err := utils.NewHistory()
+		if err != nil {
+			t.Fatalf("Failed to create history: %v", err)
+		}
+		defer history.Close()
+
+		err = history.Add(utils.Record{Assistant: "default", Session: utils.DefaultSession, Role: "user", Content: "what is this?", Images: []string{imagePath}})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		records, err := history.Fetch("default", 10)
+		if err != nil || len(records) != 1 {
+			t.Fatalf("Expected 1 record, got %d, %v", len(records), err)
+		}
+		if len(records[0].Images) != 1 || records[0].Images[0] != imagePath {
+			t.Errorf("Expected image reference %q, got %v", imagePath, records[0].Images)
+		}
+	})
+}
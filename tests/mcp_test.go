@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/llm/mcp"
+)
+
+const (
+	// stubEnv makes the test binary act as the stub MCP server
+	stubEnv = "LLMCLI_MCP_STUB"
+	// stubToolsOnlyEnv makes the stub server offer only tools
+	stubToolsOnlyEnv = "LLMCLI_MCP_STUB_TOOLS_ONLY"
+	// stubOrphanEnv makes the stub server leave a process holding its stdout
+	stubOrphanEnv = "LLMCLI_MCP_STUB_ORPHAN"
+	// stubBigOutput is the size of the output of the stub's big tool and resource
+	stubBigOutput = 100 * 1024
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEnv) == "1" {
+		runStubServer()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runStubServer is a minimal MCP server on stdin and stdout with an echo
+// tool, a tool with side effects, a failing tool, a tool counting its calls,
+// a tool with a large output and two resources. With
+// stubToolsOnlyEnv set it declares no resources and, like some servers,
+// never answers methods it does not know. With stubOrphanEnv set it starts
+// a process that keeps its stdout open after it exits.
+func runStubServer() {
+	toolsOnly := os.Getenv(stubToolsOnlyEnv) == "1"
+	if os.Getenv(stubOrphanEnv) == "1" {
+		orphan := exec.Command("sleep", "10")
+		orphan.Stdout = os.Stdout
+		orphan.Start()
+	}
+	calls := 0
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var message struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string `json:"name"`
+				Arguments struct {
+					Text string `json:"text"`
+				} `json:"arguments"`
+				URI string `json:"uri"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil || message.ID == nil {
+			continue
+		}
+
+		var result string
+		switch {
+		case toolsOnly && message.Method == "initialize":
+			result = `{"protocolVersion":"2025-03-26","capabilities":{"tools":{}},"serverInfo":{"name":"stub","version":"1"}}`
+		case toolsOnly && strings.HasPrefix(message.Method, "resources/"):
+			continue
+		case message.Method == "initialize":
+			result = `{"protocolVersion":"2025-03-26","capabilities":{"tools":{},"resources":{}},"serverInfo":{"name":"stub","version":"1"}}`
+		case message.Method == "tools/list":
+			// Notifications in between replies are ignored by the client
+			fmt.Println(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"listing"}}`)
+			result = `{"tools":[` +
+				`{"name":"echo","description":"Echo text","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}},"annotations":{"readOnlyHint":true}},` +
+				`{"name":"add note","description":"Add a note","inputSchema":{"type":"object","properties":{"text":{"type":"string"}}}},` +
+				`{"name":"fail","inputSchema":{"type":"object"}},` +
+				`{"name":"count","annotations":{"readOnlyHint":true}},` +
+				`{"name":"big","annotations":{"readOnlyHint":true}}]}`
+		case message.Method == "tools/call" && message.Params.Name == "count":
+			calls++
+			result = fmt.Sprintf(`{"content":[{"type":"text","text":"call %d"}]}`, calls)
+		case message.Method == "tools/call" && message.Params.Name == "big":
+			result = fmt.Sprintf(`{"content":[{"type":"text","text":"%s"}]}`, strings.Repeat("x", stubBigOutput))
+		case message.Method == "tools/call":
+			text, _ := json.Marshal(message.Params.Name + ": " + message.Params.Arguments.Text)
+			result = fmt.Sprintf(`{"content":[{"type":"text","text":%s}],"isError":%t}`, text, message.Params.Name == "fail")
+		case message.Method == "resources/list":
+			result = `{"resources":[{"uri":"note://today","name":"Today","description":"Notes of the day"},{"uri":"note://big"}]}`
+		case message.Method == "resources/read" && message.Params.URI == "note://big":
+			result = fmt.Sprintf(`{"contents":[{"uri":"note://big","text":"%s"}]}`, strings.Repeat("x", stubBigOutput))
+		case message.Method == "resources/read":
+			if message.Params.URI != "note://today" {
+				fmt.Printf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32002,"message":"resource not found"}}`+"\n", message.ID)
+				continue
+			}
+			result = `{"contents":[{"uri":"note://today","text":"buy milk"}]}`
+		default:
+			fmt.Printf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`+"\n", message.ID)
+			continue
+		}
+		fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":%s}`+"\n", message.ID, result)
+	}
+}
+
+// stubServers configures the test binary as the MCP server "stub"
+func stubServers(t *testing.T) map[string]config.MCPServerConfig {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test binary: %v", err)
+	}
+	return map[string]config.MCPServerConfig{
+		"stub":       {Command: executable, Env: map[string]string{stubEnv: "1"}, Timeout: 5},
+		"tools-only": {Command: executable, Env: map[string]string{stubEnv: "1", stubToolsOnlyEnv: "1"}, Timeout: 5},
+		"orphan":     {Command: executable, Env: map[string]string{stubEnv: "1", stubOrphanEnv: "1"}, Timeout: 5},
+		"broken":     {Command: "sh", Args: []string{"-c", "echo cannot start >&2; exit 1"}},
+	}
+}
+
+func TestMCPServers(t *testing.T) {
+	servers, available, err := mcp.StartServers([]string{"stub"}, stubServers(t))
+	if err != nil {
+		t.Fatalf("StartServers failed: %v", err)
+	}
+	defer servers.Close()
+
+	t.Run("Discovery", func(t *testing.T) {
+		for _, name := range []string{"stub__echo", "stub__add_note", "stub__fail", "stub__read_resource"} {
+			if _, ok := available[name]; !ok {
+				t.Errorf("Expected tool %s, got %v", name, available)
+			}
+		}
+		if available["stub__echo"].SideEffects() {
+			t.Error("Expected read-only tool to run without confirmation")
+		}
+		if !available["stub__add_note"].SideEffects() {
+			t.Error("Expected tool without read-only hint to need confirmation")
+		}
+		definition := available["stub__read_resource"].Definition()
+		if !strings.Contains(definition.Description, "note://today (Today): Notes of the day") {
+			t.Errorf("Expected resources in description, got %q", definition.Description)
+		}
+	})
+
+	tests := []struct {
+		tool      string
+		arguments string
+		want      string
+		err       string
+	}{
+		{"stub__echo", `{"text":"hi"}`, "echo: hi", ""},
+		{"stub__add_note", `{"text":"milk"}`, "add note: milk", ""},
+		{"stub__fail", ``, "", "fail: "},
+		{"stub__read_resource", `{"uri":"note://today"}`, "buy milk", ""},
+		{"stub__read_resource", `{"uri":"note://nope"}`, "", "resource not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool+" "+tt.arguments, func(t *testing.T) {
+			result, err := available[tt.tool].Run(tt.arguments)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, result)
+			}
+		})
+	}
+
+	t.Run("Output Is Truncated", func(t *testing.T) {
+		for _, call := range []struct{ tool, arguments string }{
+			{"stub__big", ``},
+			{"stub__read_resource", `{"uri":"note://big"}`},
+		} {
+			result, err := available[call.tool].Run(call.arguments)
+			if err != nil {
+				t.Fatalf("Run %s failed: %v", call.tool, err)
+			}
+			if len(result) >= stubBigOutput || !strings.Contains(result, "[truncated ") {
+				t.Errorf("Expected the output of %s to be truncated, got %d bytes", call.tool, len(result))
+			}
+		}
+	})
+
+	t.Run("Only Declared Capabilities", func(t *testing.T) {
+		start := time.Now()
+		servers, available, err := mcp.StartServers([]string{"tools-only"}, stubServers(t))
+		if err != nil {
+			t.Fatalf("StartServers failed: %v", err)
+		}
+		defer servers.Close()
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected resources not to be listed, discovery took %s", elapsed)
+		}
+		if _, ok := available["tools-only__read_resource"]; ok {
+			t.Error("Expected no resource tool for a server without resources")
+		}
+		if _, ok := available["tools-only__echo"]; !ok {
+			t.Errorf("Expected tools to be listed, got %v", available)
+		}
+	})
+
+	t.Run("Close With Orphaned Stdout", func(t *testing.T) {
+		servers, _, err := mcp.StartServers([]string{"orphan"}, stubServers(t))
+		if err != nil {
+			t.Fatalf("StartServers failed: %v", err)
+		}
+		closed := make(chan struct{})
+		go func() {
+			servers.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(8 * time.Second):
+			t.Fatal("Expected Close to return while another process holds the server's stdout")
+		}
+	})
+
+	t.Run("Unknown Server", func(t *testing.T) {
+		if _, _, err := mcp.StartServers([]string{"missing"}, stubServers(t)); err == nil {
+			t.Error("Expected error for unknown server")
+		}
+	})
+
+	t.Run("Server Exits", func(t *testing.T) {
+		_, _, err := mcp.StartServers([]string{"broken"}, stubServers(t))
+		if err == nil || !strings.Contains(err.Error(), "exited") {
+			t.Errorf("Expected error about the server exiting, got %v", err)
+		}
+	})
+}
+
+func TestAssistantMCPTools(t *testing.T) {
+	dir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := newToolServer(t, "stub__echo", `{"text":"hi"}`)
+	defer server.Close()
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"agent": {Model: "mock", Prompt: "Use tools.", MCPServers: []string{"stub"}},
+		},
+		MCPServers: stubServers(t),
+	})
+
+	response, err := llm.AssistantCall("agent", "say hi")
+	if err != nil {
+		t.Fatalf("AssistantCall failed: %v", err)
+	}
+	if response != "tool said: echo: hi" {
+		t.Errorf("Expected MCP tool result in answer, got %q", response)
+	}
+}
+
+func TestAssistantMCPServersKeptRunning(t *testing.T) {
+	dir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := newToolServer(t, "stub__count", `{}`)
+	defer server.Close()
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"agent": {Model: "mock", Prompt: "Use tools.", MCPServers: []string{"stub"}},
+		},
+		MCPServers: stubServers(t),
+	})
+
+	servers := mcp.NewServers(stubServers(t))
+	defer servers.Close()
+	for _, want := range []string{"tool said: call 1", "tool said: call 2"} {
+		response, err := llm.AssistantCallWithOptions("agent", "count", llm.AssistantOptions{MCPServers: servers})
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if response != want {
+			t.Errorf("Expected %q from a server kept running between calls, got %q", want, response)
+		}
+	}
+
+	// Without shared servers each call starts a new server
+	response, err := llm.AssistantCall("agent", "count")
+	if err != nil || response != "tool said: call 1" {
+		t.Errorf("Expected a fresh server, got %q (%v)", response, err)
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"llm_cli/utils"
+)
+
+// testOptions is the target of the options parsed in TestParseOptions
+type testOptions struct {
+	Files []string
+	JSON  bool
+	New   string
+}
+
+var testOptionSpecs = map[string]utils.OptionSpec[testOptions]{
+	"-f": {Value: true, Set: func(o *testOptions, v string) error {
+		if v == "" {
+			return fmt.Errorf("empty path")
+		}
+		o.Files = append(o.Files, v)
+		return nil
+	}},
+	"--json": {Set: func(o *testOptions, v string) error {
+		o.JSON = true
+		return nil
+	}},
+	"--new": {Optional: true, Set: func(o *testOptions, v string) error {
+		o.New = "set:" + v
+		return nil
+	}},
+}
+
+// afterName ends the options at the word after "-a name", like llmcli
+// does at the first word of a prompt
+func afterName(positional []string, arg string) bool {
+	return len(positional) == 2 && positional[0] == "-a"
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		stop func(positional []string, arg string) bool
+		want testOptions
+		rest []string
+		err  string
+	}{
+		{
+			name: "Anywhere",
+			args: []string{"-a", "coder", "-f", "main.go", "explain", "--json", "-f=flags.go", "this"},
+			want: testOptions{Files: []string{"main.go", "flags.go"}, JSON: true},
+			rest: []string{"-a", "coder", "explain", "this"},
+		},
+		{
+			name: "Stop At First Operand",
+			args: []string{"--json", "-a", "-f", "main.go", "coder", "-f=flags.go", "explain", "what", "tar", "-f", "does", "--", "--json"},
+			stop: afterName,
+			want: testOptions{Files: []string{"main.go", "flags.go"}, JSON: true},
+			rest: []string{"-a", "coder", "explain", "what", "tar", "-f", "does", "--", "--json"},
+		},
+		{
+			name: "End Of Options Before First Operand",
+			args: []string{"-a", "coder", "--", "-f", "does"},
+			stop: afterName,
+			rest: []string{"-a", "coder", "-f", "does"},
+		},
+		{
+			name: "Optional Value",
+			args: []string{"--new", "hi", "--new=named"},
+			want: testOptions{New: "set:named"},
+			rest: []string{"hi"},
+		},
+		{
+			name: "End Of Options",
+			args: []string{"-a", "coder", "-f", "notes.md", "--", "explain", "what", "tar", "-f", "does", "--json", "--"},
+			want: testOptions{Files: []string{"notes.md"}},
+			rest: []string{"-a", "coder", "explain", "what", "tar", "-f", "does", "--json", "--"},
+		},
+		{name: "Missing Value", args: []string{"explain", "-f"}, err: "option -f requires a value"},
+		{name: "Unexpected Value", args: []string{"--json=yes"}, err: "option --json does not take a value"},
+		{name: "Invalid Value", args: []string{"-f="}, err: "invalid value for -f: empty path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options testOptions
+			rest, err := utils.ParseOptions(tt.args, &options, testOptionSpecs, tt.stop)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOptions failed: %v", err)
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.rest, " ") {
+				t.Errorf("Expected positional arguments %q, got %q", tt.rest, rest)
+			}
+			if strings.Join(options.Files, ",") != strings.Join(tt.want.Files, ",") || options.JSON != tt.want.JSON || options.New != tt.want.New {
+				t.Errorf("Expected options %+v, got %+v", tt.want, options)
+			}
+		})
+	}
+}
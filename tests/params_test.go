@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm/api"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+func intPtr(i int) *int           { return &i }
+
+func TestGenerationParamsConfig(t *testing.T) {
+	content := `{
+		"models": {"m": {"API": "OpenAI", "Model": "gpt-4o", "temperature": 0.7, "maxTokens": 500}},
+		"assistants": {"a": {"model": "m", "prompt": "p", "temperature": 0.2, "stop": ["END"]}}
+	}`
+
+	var cfg config.Config
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	// Model < assistant < command line
+	params := cfg.Models["m"].GenerationParams.
+		Merge(cfg.Assistants["a"].GenerationParams).
+		Merge(api.GenerationParams{MaxTokens: intPtr(800)})
+
+	if params.Temperature == nil || *params.Temperature != 0.2 {
+		t.Errorf("Expected assistant temperature 0.2, got %v", params.Temperature)
+	}
+	if params.MaxTokens == nil || *params.MaxTokens != 800 {
+		t.Errorf("Expected command line max tokens 800, got %v", params.MaxTokens)
+	}
+	if len(params.Stop) != 1 || params.Stop[0] != "END" {
+		t.Errorf("Expected stop sequence from assistant, got %v", params.Stop)
+	}
+}
+
+func TestGenerationParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  api.GenerationParams
+		wantErr bool
+	}{
+		{name: "empty", params: api.GenerationParams{}},
+		{name: "valid", params: api.GenerationParams{Temperature: floatPtr(0.2), TopP: floatPtr(0.9), ResponseFormat: "json_object"}},
+		{name: "temperature too high", params: api.GenerationParams{Temperature: floatPtr(3)}, wantErr: true},
+		{name: "negative max tokens", params: api.GenerationParams{MaxTokens: intPtr(-1)}, wantErr: true},
+		{name: "unknown response format", params: api.GenerationParams{ResponseFormat: "xml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerationParamsForwarding(t *testing.T) {
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		body := req.Body
+		if body["temperature"] != 0.2 || body["max_tokens"] != float64(800) || body["seed"] != float64(7) {
+			t.Errorf("Expected generation parameters in request, got %v", body)
+		}
+		if format, ok := body["response_format"].(map[string]interface{}); !ok || format["type"] != "json_object" {
+			t.Errorf("Expected response_format json_object, got %v", body["response_format"])
+		}
+		if _, ok := body["top_p"]; ok {
+			t.Error("Expected unset top_p to be omitted")
+		}
+		return http.StatusOK, chatReply("{}")
+	})
+	defer server.Close()
+
+	params := api.GenerationParams{
+		Temperature:    floatPtr(0.2),
+		MaxTokens:      intPtr(800),
+		Seed:           intPtr(7),
+		ResponseFormat: api.ResponseFormatJSON,
+	}
+
+	t.Run("OpenAI Compatible", func(t *testing.T) {
+		_, err := api.Providers["OpenAICompatible"].Call(api.Request{
+			Model:    "mock-model",
+			Messages: []api.Message{{Role: "user", Content: "hi"}},
+			BaseURL:  server.URL,
+			Params:   params,
+		})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := api.Providers["Anthropic"].Call(api.Request{
+			Model:    "claude-test",
+			Messages: []api.Message{{Role: "user", Content: "hi"}},
+			BaseURL:  server.URL,
+			Params:   params,
+		})
+		if err == nil || !strings.Contains(err.Error(), "seed") || !strings.Contains(err.Error(), "response_format") {
+			t.Errorf("Expected error naming unsupported parameters, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "style.md"), []byte("Use short sentences."), 0644)
+	os.WriteFile(filepath.Join(dir, "logo.png"), []byte{0x89, 'P', 'N', 'G', 0, 0, 0}, 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("hunter2"), 0644)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "tone.md"), []byte("Be kind."), 0644)
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(dir, "docs", "leak.md"))
+	os.Symlink(filepath.Join(dir, "docs", "tone.md"), filepath.Join(dir, "tone.md"))
+	chdir(t, dir)
+	cwd, _ := os.Getwd()
+	os.Setenv("LLMCLI_TEST_USER", "ada")
+	defer os.Unsetenv("LLMCLI_TEST_USER")
+
+	vars := llm.PromptVars{
+		Vars:  map[string]string{"lang": "go", "OS": "plan9"},
+		Stdin: "diff --git",
+		Files: []string{"style.md", "logo.png", "nope.md", "tone.md", filepath.Join(dir, "docs", "*.md")},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		err      string
+	}{
+		{"invalid template", "You are {{ not a template", "", "invalid template"},
+		{"no actions", "You are helpful.", "You are helpful.", ""},
+		{"variables", "Review {{.lang}} code: {{.Stdin}}", "Review go code: diff --git", ""},
+		{"built-ins", "{{.Date}} in {{.Cwd}}", time.Now().Format("2006-01-02") + " in " + cwd, ""},
+		{"vars override built-ins", "{{.OS}}", "plan9", ""},
+		{"file include", `Style: {{file "style.md"}}`, "Style: Use short sentences.", ""},
+		{"environment", `Hi {{env "LLMCLI_TEST_USER"}}`, "Hi ada", ""},
+		{"missing variable", "{{.project}}", "", `no entry for key "project"`},
+		{"missing file", `{{file "nope.md"}}`, "", "nope.md"},
+		{"binary file", `{{file "logo.png"}}`, "", "binary"},
+		{"glob", `{{file "docs/tone.md"}}`, "Be kind.", ""},
+		{"allowed link", `{{file "tone.md"}}`, "Be kind.", ""},
+		{"file not listed", `{{file "secret.txt"}}`, "", "secret.txt is not listed in promptFiles"},
+		{"absolute path not listed", `{{file "` + filepath.Join(dir, "secret.txt") + `"}}`, "", "not listed in promptFiles"},
+		{"link out of the list", `{{file "docs/leak.md"}}`, "", "which is not listed in promptFiles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := llm.RenderPrompt("test", tt.template, vars)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderPrompt failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("No Files Allowed", func(t *testing.T) {
+		if _, err := llm.RenderPrompt("test", `{{file "style.md"}}`, llm.PromptVars{}); err == nil {
+			t.Error("Expected {{file}} to be refused without promptFiles")
+		}
+	})
+
+	t.Run("Built-in OS", func(t *testing.T) {
+		got, err := llm.RenderPrompt("test", "{{.OS}}", llm.PromptVars{})
+		if err != nil || got != runtime.GOOS {
+			t.Errorf("Expected %q, got %q (%v)", runtime.GOOS, got, err)
+		}
+	})
+
+	t.Run("Git Branch", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+		got, _ := llm.RenderPrompt("test", "[{{.GitBranch}}]", llm.PromptVars{})
+		if got != "[]" {
+			t.Errorf("Expected no branch outside a repository, got %q", got)
+		}
+		if err := exec.Command("git", "init", "-q", "-b", "feature-x").Run(); err != nil {
+			t.Skipf("git init failed: %v", err)
+		}
+		got, err := llm.RenderPrompt("test", "[{{.GitBranch}}]", llm.PromptVars{})
+		if err != nil || got != "[feature-x]" {
+			t.Errorf("Expected [feature-x], got %q (%v)", got, err)
+		}
+	})
+}
+
+func TestAssistantPromptTemplate(t *testing.T) {
+	dir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		return http.StatusOK, chatReply(req.Messages[0].Content)
+	})
+	defer server.Close()
+
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"coder": {Model: "mock", Prompt: "You write {{.lang}}.", PromptTemplate: true},
+			"plain": {Model: "mock", Prompt: "Explain {{.Name}} in Go templates and {{ name }} in Jinja."},
+		},
+	})
+
+	response, err := llm.AssistantCallWithOptions("coder", "hi", llm.AssistantOptions{
+		Vars: llm.PromptVars{Vars: map[string]string{"lang": "Go"}},
+	})
+	if err != nil {
+		t.Fatalf("AssistantCall failed: %v", err)
+	}
+	if response != "You write Go." {
+		t.Errorf("Expected rendered system prompt, got %q", response)
+	}
+
+	_, err = llm.AssistantCall("coder", "hi")
+	if err == nil || !strings.Contains(err.Error(), "lang") {
+		t.Errorf("Expected error about the missing variable, got %v", err)
+	}
+
+	response, err = llm.AssistantCall("plain", "hi")
+	if err != nil {
+		t.Fatalf("AssistantCall failed: %v", err)
+	}
+	if response != "Explain {{.Name}} in Go templates and {{ name }} in Jinja." {
+		t.Errorf("Expected a prompt without promptTemplate to be sent as written, got %q", response)
+	}
+}
+
+func TestPromptUses(t *testing.T) {
+	tests := []struct {
+		template string
+		want     bool
+	}{
+		{"Summarize:\n{{.Stdin}}", true},
+		{"{{if .Stdin}}Input: {{.Stdin}}{{end}}", true},
+		{"{{range .items}}{{$.Stdin}}{{end}}", true},
+		{`{{define "body"}}{{.Stdin}}{{end}}{{template "body" .}}`, true},
+		{"Read .Stdin yourself", false},
+		{"{{.StdinNote}} and {{.lang}}", false},
+		{"no template", false},
+	}
+	for _, tt := range tests {
+		got, err := llm.PromptUses(tt.template, "Stdin")
+		if err != nil {
+			t.Fatalf("PromptUses(%q) failed: %v", tt.template, err)
+		}
+		if got != tt.want {
+			t.Errorf("PromptUses(%q) = %v, want %v", tt.template, got, tt.want)
+		}
+	}
+
+	if _, err := llm.PromptUses("{{.Stdin", "Stdin"); err == nil {
+		t.Error("Expected error for an invalid template")
+	}
+}
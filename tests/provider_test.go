@@ -0,0 +1,375 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"llm_cli/llm/api"
+)
+
+// newMockCompletionServer returns a server speaking the OpenAI chat
+// completions API that echoes the last user message back. Like OpenAI, it
+// ends streams with a usage chunk only if stream_options asks for it.
+func newMockCompletionServer(t *testing.T) *httptest.Server {
+	return newChatServer(t, func(req chatRequest) (int, string) {
+		if req.Path != "/v1/chat/completions" {
+			return http.StatusNotFound, "404 page not found"
+		}
+		if req.Query.Get("api-version") != "2024-06-01" {
+			t.Errorf("Expected api-version query parameter, got %q", req.Query.Encode())
+		}
+		if req.Header.Get("api-key") != "secret" {
+			t.Errorf("Expected api-key header, got %q", req.Header.Get("api-key"))
+		}
+		if req.Header.Get("Authorization") != "" {
+			t.Errorf("Expected no Authorization header without API key, got %q", req.Header.Get("Authorization"))
+		}
+
+		reply := "echo: " + req.LastMessage().Content
+		if !req.Stream {
+			return http.StatusOK, fmt.Sprintf(`{"choices":[{"message":{"content":%q}}],"usage":{"prompt_tokens":3,"completion_tokens":4}}`, reply)
+		}
+
+		var chunks []string
+		for _, word := range strings.SplitAfter(reply, " ") {
+			chunks = append(chunks, chatDelta(word))
+		}
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			chunks = append(chunks, `{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4}}`)
+		}
+		return http.StatusOK, chatEvents(chunks...)
+	})
+}
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	server := newMockCompletionServer(t)
+	defer server.Close()
+
+	provider := api.Providers["OpenAICompatible"]
+	req := api.Request{
+		Model:       "mock-model",
+		Messages:    []api.Message{{Role: "user", Content: "hello there"}},
+		BaseURL:     server.URL + "/v1",
+		Headers:     map[string]string{"api-key": "secret"},
+		QueryParams: map[string]string{"api-version": "2024-06-01"},
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		response, err := provider.Call(req)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response.Content != "echo: hello there" {
+			t.Errorf("Expected 'echo: hello there', got '%s'", response.Content)
+		}
+		if response.Usage != (api.Usage{PromptTokens: 3, CompletionTokens: 4}) {
+			t.Errorf("Expected usage 3/4, got %+v", response.Usage)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		streamer, ok := provider.(api.StreamProvider)
+		if !ok {
+			t.Fatal("Expected provider to support streaming")
+		}
+
+		var deltas []string
+		response, err := streamer.Stream(req, func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if response.Content != "echo: hello there" {
+			t.Errorf("Expected 'echo: hello there', got '%s'", response.Content)
+		}
+		if len(deltas) != 3 {
+			t.Errorf("Expected 3 deltas, got %d: %q", len(deltas), deltas)
+		}
+		if response.Usage != (api.Usage{}) {
+			t.Errorf("Expected no usage chunk to be requested by default, got %+v", response.Usage)
+		}
+	})
+
+	t.Run("Stream Usage", func(t *testing.T) {
+		enabled := true
+		withUsage := req
+		withUsage.StreamUsage = &enabled
+		response, err := provider.(api.StreamProvider).Stream(withUsage, func(string) {})
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if response.Usage != (api.Usage{PromptTokens: 3, CompletionTokens: 4}) {
+			t.Errorf("Expected usage 3/4, got %+v", response.Usage)
+		}
+	})
+
+	t.Run("Missing BaseURL", func(t *testing.T) {
+		noURL := req
+		noURL.BaseURL = ""
+		if _, err := provider.Call(noURL); err == nil {
+			t.Error("Expected error without BaseURL")
+		}
+	})
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Error("Expected anthropic-version header")
+		}
+
+		var body struct {
+			System    string        `json:"system"`
+			Messages  []api.Message `json:"messages"`
+			MaxTokens int           `json:"max_tokens"`
+			Stream    bool          `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if body.System != "be brief" {
+			t.Errorf("Expected system prompt 'be brief', got '%s'", body.System)
+		}
+		if len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+			t.Errorf("Expected system message to be removed from messages, got %+v", body.Messages)
+		}
+		if body.MaxTokens <= 0 {
+			t.Errorf("Expected max_tokens to be set, got %d", body.MaxTokens)
+		}
+
+		if !body.Stream {
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn"}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5,\"output_tokens\":1}}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"h\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"i\"}}\n\n")
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":2}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	provider := api.Providers["Anthropic"]
+	req := api.Request{
+		Model: "claude-test",
+		Messages: []api.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hello"},
+		},
+		APIKey:  "test-key",
+		BaseURL: server.URL + "/v1",
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		response, err := provider.Call(req)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response.Content != "hi" {
+			t.Errorf("Expected 'hi', got '%s'", response.Content)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		response, err := provider.(api.StreamProvider).Stream(req, func(string) {})
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if response.Content != "hi" {
+			t.Errorf("Expected 'hi', got '%s'", response.Content)
+		}
+		if response.Usage != (api.Usage{PromptTokens: 5, CompletionTokens: 2}) {
+			t.Errorf("Expected usage 5/2, got %+v", response.Usage)
+		}
+	})
+
+	t.Run("Error Body", func(t *testing.T) {
+		badKey := req
+		badKey.APIKey = "wrong"
+		_, err := provider.Call(badKey)
+		if err == nil || !strings.Contains(err.Error(), "invalid x-api-key") {
+			t.Errorf("Expected readable API error, got %v", err)
+		}
+	})
+}
+
+func TestGeminiProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:generateContent" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("Expected x-goog-api-key header, got %q", r.Header.Get("x-goog-api-key"))
+		}
+
+		var body struct {
+			Contents []struct {
+				Role  string `json:"role"`
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+			SystemInstruction struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"systemInstruction"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if len(body.SystemInstruction.Parts) != 1 || body.SystemInstruction.Parts[0].Text != "be brief" {
+			t.Errorf("Expected system instruction 'be brief', got %+v", body.SystemInstruction)
+		}
+		if len(body.Contents) != 3 || body.Contents[1].Role != "model" {
+			t.Errorf("Expected assistant role mapped to model, got %+v", body.Contents)
+		}
+
+		last := body.Contents[len(body.Contents)-1].Parts[0].Text
+		if last == "something unsafe" {
+			fmt.Fprint(w, `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH"}]}}`)
+			return
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	provider := api.Providers["Gemini"]
+	req := api.Request{
+		Model: "gemini-test",
+		Messages: []api.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hello!"},
+			{Role: "user", Content: "how are you"},
+		},
+		APIKey:  "test-key",
+		BaseURL: server.URL + "/v1beta",
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		response, err := provider.Call(req)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response.Content != "hi" {
+			t.Errorf("Expected 'hi', got '%s'", response.Content)
+		}
+	})
+
+	t.Run("Safety Block", func(t *testing.T) {
+		blocked := req
+		blocked.Messages = append(append([]api.Message{}, req.Messages[:3]...), api.Message{Role: "user", Content: "something unsafe"})
+
+		_, err := provider.Call(blocked)
+		var safetyErr *api.SafetyBlockError
+		if !errors.As(err, &safetyErr) {
+			t.Fatalf("Expected SafetyBlockError, got %v", err)
+		}
+		if !safetyErr.Prompt || safetyErr.Reason != "SAFETY" {
+			t.Errorf("Unexpected safety error: %+v", safetyErr)
+		}
+	})
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3:latest"},{"name":"qwen2.5:7b"}]}`)
+		case "/api/chat":
+			var body struct {
+				Model  string `json:"model"`
+				Stream bool   `json:"stream"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Failed to decode request: %v", err)
+			}
+			if body.Model != "llama3" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"model \"%s\" not found, try pulling it first"}`, body.Model)
+				return
+			}
+			if !body.Stream {
+				fmt.Fprint(w, `{"message":{"role":"assistant","content":"hi"},"done":true}`)
+				return
+			}
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"h"},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"i"},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := api.Providers["Ollama"]
+	req := api.Request{
+		Model:    "llama3",
+		Messages: []api.Message{{Role: "user", Content: "hello"}},
+		BaseURL:  server.URL,
+	}
+
+	t.Run("Call", func(t *testing.T) {
+		response, err := provider.Call(req)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response.Content != "hi" {
+			t.Errorf("Expected 'hi', got '%s'", response.Content)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		var deltas []string
+		response, err := provider.(api.StreamProvider).Stream(req, func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if response.Content != "hi" || len(deltas) != 2 {
+			t.Errorf("Expected 'hi' in 2 deltas, got '%s' in %d", response.Content, len(deltas))
+		}
+	})
+
+	t.Run("List Models", func(t *testing.T) {
+		lister := provider.(api.ModelLister)
+		models, err := lister.ListModels(req)
+		if err != nil {
+			t.Fatalf("ListModels failed: %v", err)
+		}
+		if len(models) != 2 {
+			t.Errorf("Expected 2 models, got %v", models)
+		}
+		if !lister.HasModel(models, "llama3") {
+			t.Error("Expected untagged 'llama3' to match 'llama3:latest'")
+		}
+		if lister.HasModel(models, "mistral") {
+			t.Error("Expected 'mistral' not to be installed")
+		}
+	})
+
+	t.Run("Missing Model", func(t *testing.T) {
+		missing := req
+		missing.Model = "mistral"
+		_, err := provider.Call(missing)
+		if err == nil || !strings.Contains(err.Error(), "llama3:latest") {
+			t.Errorf("Expected error listing installed models, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/repl"
+	"llm_cli/utils"
+)
+
+// scriptedReader is a repl.LineReader returning fixed lines and recording
+// the prompt each line was read with
+type scriptedReader struct {
+	lines   []string
+	prompt  string
+	prompts []string
+}
+
+func (r *scriptedReader) Readline() (string, error) {
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	r.prompts = append(r.prompts, r.prompt)
+	return line, nil
+}
+
+func (r *scriptedReader) SetPrompt(prompt string) { r.prompt = prompt }
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		prompts []string
+		err     error
+	}{
+		{name: "Single Line", lines: []string{"hello"}, want: "hello", prompts: []string{"> "}},
+		{
+			name:    "Backslash Continuation",
+			lines:   []string{`first \`, `second\`, "third", "next"},
+			want:    "first \nsecond\nthird",
+			prompts: []string{"> ", "... ", "... "},
+		},
+		{
+			name:    "Block",
+			lines:   []string{`"""`, "line one", "", `  line two\`, ` """ `, "next"},
+			want:    "line one\n\n  line two\\",
+			prompts: []string{"> ", "... ", "... ", "... ", "... "},
+		},
+		{name: "Unterminated Block", lines: []string{`"""`, "line one"}, err: io.EOF},
+		{name: "End Of Input", err: io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &scriptedReader{lines: tt.lines}
+			input, err := repl.ReadInput(reader, "> ")
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if input != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, input)
+			}
+			if tt.prompts != nil && strings.Join(reader.prompts, "|") != strings.Join(tt.prompts, "|") {
+				t.Errorf("Expected prompts %q, got %q", tt.prompts, reader.prompts)
+			}
+			if reader.prompt != "> " {
+				t.Errorf("Expected the prompt to be restored, got %q", reader.prompt)
+			}
+		})
+	}
+}
+
+func TestREPLSession(t *testing.T) {
+	dir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"gpt":   {API: "OpenAI", Model: "gpt-4o"},
+			"llama": {API: "Ollama", Model: "llama3"},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"coder":  {Model: "gpt"},
+			"writer": {Model: "llama"},
+		},
+	})
+
+	// newSession returns a session whose replies echo the input, with the
+	// calls of its hooks recorded in calls
+	newSession := func(calls *[]string) (*repl.Session, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		session := &repl.Session{Assistant: "coder", Out: out}
+		session.Chat = func(input string) (string, error) {
+			*calls = append(*calls, fmt.Sprintf("chat %s/%s/%s: %s", session.Assistant, session.Model, session.Session, input))
+			if input == "fail" {
+				return "", fmt.Errorf("model unavailable")
+			}
+			return "echo: " + input, nil
+		}
+		session.PrintHistory = func(assistant, name string, limit int) {
+			*calls = append(*calls, fmt.Sprintf("history %s/%s/%d", assistant, name, limit))
+		}
+		session.ListSessions = func(assistant string) {
+			*calls = append(*calls, "sessions "+assistant)
+		}
+		return session, out
+	}
+
+	t.Run("Run", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		reader := &scriptedReader{lines: []string{"  ", `explain \`, "this", "fail", "/exit", "never read"}}
+		session.Run(reader)
+
+		want := []string{"chat coder//: explain \nthis", "chat coder//: fail"}
+		if strings.Join(calls, "|") != strings.Join(want, "|") {
+			t.Errorf("Expected calls %q, got %q", want, calls)
+		}
+		if len(session.Turns) != 1 || session.Turns[0].Response != "echo: explain \nthis" {
+			t.Errorf("Expected only the answered message as a turn, got %+v", session.Turns)
+		}
+		if !strings.Contains(out.String(), "Error: model unavailable") {
+			t.Errorf("Expected the chat error to be shown, got %q", out.String())
+		}
+		if len(reader.lines) != 1 {
+			t.Errorf("Expected /exit to end the session, %d lines left", len(reader.lines))
+		}
+	})
+
+	t.Run("Model", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		for _, line := range []string{"/model", "/model missing", "/model llama", "hi", "/model", "/model -"} {
+			if !session.Handle(line) {
+				t.Fatalf("Expected %s to keep the session going", line)
+			}
+		}
+		for _, want := range []string{"Current model: gpt (assistant default)", "Model 'missing' not found in config",
+			"Using model 'llama' for this session", "Current model: llama", "Model override cleared"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected output to contain %q, got %q", want, out.String())
+			}
+		}
+		if session.Model != "" || len(calls) != 1 || calls[0] != "chat coder/llama/: hi" {
+			t.Errorf("Expected the override to apply to the chat and then be cleared, got %q (model %q)", calls, session.Model)
+		}
+	})
+
+	t.Run("Assistant", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		session.Session = "resumed"
+		session.Handle("/assistant")
+		session.Handle("/assistant nobody")
+		if session.Assistant != "coder" || session.Session != "resumed" {
+			t.Errorf("Expected an unknown assistant to change nothing, got %q/%q", session.Assistant, session.Session)
+		}
+		session.Handle("/assistant writer")
+		session.Handle("hi")
+		for _, want := range []string{"Available assistants: coder, writer", "Assistant 'nobody' not found", "Switched to assistant 'writer'"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected output to contain %q, got %q", want, out.String())
+			}
+		}
+		if len(calls) != 1 || calls[0] != "chat writer//: hi" {
+			t.Errorf("Expected the chat to go to the new assistant's current session, got %q", calls)
+		}
+	})
+
+	t.Run("History And Sessions", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		for _, line := range []string{"/history", "/history 3", "/new feature-x", "/history x", "/session", "/session main", "/history"} {
+			session.Handle(line)
+		}
+		want := []string{"history coder/default/10", "history coder/default/3", "history coder/feature-x/10", "sessions coder", "history coder/main/10"}
+		if strings.Join(calls, "|") != strings.Join(want, "|") {
+			t.Errorf("Expected calls %q, got %q", want, calls)
+		}
+		if !strings.Contains(out.String(), "Started session 'feature-x' for assistant 'coder'") || !strings.Contains(out.String(), "Switched to session 'main'") {
+			t.Errorf("Expected session switches to be reported, got %q", out.String())
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		history, err := utils.NewHistory()
+		if err != nil {
+			t.Fatalf("NewHistory failed: %v", err)
+		}
+		defer history.Close()
+		if err := history.PushSession("coder", "scratch", "user", "forget me"); err != nil {
+			t.Fatalf("PushSession failed: %v", err)
+		}
+		if err := history.PushSession("coder", "keep", "user", "remember me"); err != nil {
+			t.Fatalf("PushSession failed: %v", err)
+		}
+
+		var calls []string
+		session, out := newSession(&calls)
+		session.Session = "scratch"
+		session.Handle("/clear")
+		records, err := history.FetchSession("coder", "scratch", 10)
+		if err != nil {
+			t.Fatalf("FetchSession failed: %v", err)
+		}
+		if len(records) != 0 {
+			t.Errorf("Expected /clear to delete the current session, got %d records", len(records))
+		}
+		records, err = history.FetchSession("coder", "keep", 10)
+		if err != nil {
+			t.Fatalf("FetchSession failed: %v", err)
+		}
+		if len(records) != 1 {
+			t.Errorf("Expected /clear to keep other sessions, got %d records", len(records))
+		}
+		if !strings.Contains(out.String(), "Cleared session 'scratch' of assistant 'coder'") {
+			t.Errorf("Expected /clear to be reported, got %q", out.String())
+		}
+	})
+
+	t.Run("Save", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		path := filepath.Join(dir, "transcript.md")
+		session.Handle("/save " + path)
+		if !strings.Contains(out.String(), "nothing to save yet") {
+			t.Errorf("Expected an empty session not to be saved, got %q", out.String())
+		}
+
+		session.Handle("first question")
+		session.Handle("/assistant writer")
+		session.Handle("second question")
+		session.Handle("/save " + path)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected transcript to be written: %v", err)
+		}
+		for _, want := range []string{"## user → coder\n\nfirst question", "## coder\n\necho: first question",
+			"## user → writer\n\nsecond question", "## writer\n\necho: second question"} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("Expected transcript to contain %q, got %q", want, content)
+			}
+		}
+	})
+
+	t.Run("Exit And Unknown Commands", func(t *testing.T) {
+		var calls []string
+		session, out := newSession(&calls)
+		if !session.Handle("/frobnicate") || !strings.Contains(out.String(), "Unknown command /frobnicate") {
+			t.Errorf("Expected unknown commands to be reported, got %q", out.String())
+		}
+		if session.Handle("/exit") || session.Handle("/quit") {
+			t.Error("Expected /exit and /quit to end the session")
+		}
+		if len(calls) != 0 {
+			t.Errorf("Expected commands not to reach the assistant, got %q", calls)
+		}
+	})
+}
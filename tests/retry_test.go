@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"llm_cli/llm/api"
+)
+
+// newFlakyServer fails the first `failures` requests with the given status
+// and answers normally afterwards
+func newFlakyServer(t *testing.T, failures int32, status int, header map[string]string) (*httptest.Server, *int32) {
+	var hits int32
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		if atomic.AddInt32(&hits, 1) <= failures {
+			for key, value := range header {
+				req.Reply.Set(key, value)
+			}
+			return status, chatError("try again later")
+		}
+		return http.StatusOK, chatReply("ok")
+	})
+	return server, &hits
+}
+
+func TestRetry(t *testing.T) {
+	originalDelay := api.RetryBaseDelay
+	api.RetryBaseDelay = time.Millisecond
+	defer func() { api.RetryBaseDelay = originalDelay }()
+
+	provider := api.Providers["OpenAICompatible"]
+	newRequest := func(baseURL string) api.Request {
+		return api.Request{
+			Model:    "mock-model",
+			Messages: []api.Message{{Role: "user", Content: "hi"}},
+			BaseURL:  baseURL,
+		}
+	}
+
+	t.Run("Retries Server Errors", func(t *testing.T) {
+		server, hits := newFlakyServer(t, 2, http.StatusServiceUnavailable, nil)
+		defer server.Close()
+
+		response, err := provider.Call(newRequest(server.URL))
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response.Content != "ok" || *hits != 3 {
+			t.Errorf("Expected 'ok' after 3 attempts, got '%s' after %d", response.Content, *hits)
+		}
+	})
+
+	t.Run("Honors Retry-After", func(t *testing.T) {
+		server, hits := newFlakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "1"})
+		defer server.Close()
+
+		start := time.Now()
+		if _, err := provider.Call(newRequest(server.URL)); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Errorf("Expected to wait for Retry-After, retried after %v", elapsed)
+		}
+		if *hits != 2 {
+			t.Errorf("Expected 2 attempts, got %d", *hits)
+		}
+	})
+
+	t.Run("Max Attempts", func(t *testing.T) {
+		server, hits := newFlakyServer(t, 10, http.StatusInternalServerError, nil)
+		defer server.Close()
+
+		req := newRequest(server.URL)
+		req.MaxAttempts = 2
+		_, err := provider.Call(req)
+		if err == nil {
+			t.Fatal("Expected error after exhausting attempts")
+		}
+		if *hits != 2 {
+			t.Errorf("Expected 2 attempts, got %d", *hits)
+		}
+	})
+
+	t.Run("No Retry On Client Errors", func(t *testing.T) {
+		server, hits := newFlakyServer(t, 10, http.StatusBadRequest, nil)
+		defer server.Close()
+
+		if _, err := provider.Call(newRequest(server.URL)); err == nil {
+			t.Fatal("Expected error for bad request")
+		}
+		if *hits != 1 {
+			t.Errorf("Expected 1 attempt, got %d", *hits)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		server := newChatServer(t, func(req chatRequest) (int, string) {
+			time.Sleep(200 * time.Millisecond)
+			return http.StatusOK, chatReply("late")
+		})
+		defer server.Close()
+
+		req := newRequest(server.URL)
+		req.Timeout = 50 * time.Millisecond
+		req.MaxAttempts = 1
+		if _, err := provider.Call(req); err == nil {
+			t.Error("Expected timeout error")
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"llm_cli/utils"
+)
+
+func TestSearch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "search_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	if !history.FullTextSearch() {
+		if _, err := history.Search("goroutines", utils.SearchOptions{}); err != utils.ErrNoFullTextSearch {
+			t.Errorf("Expected ErrNoFullTextSearch without FTS5, got %v", err)
+		}
+		t.Skip("SQLite built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	history.Push("coding", "user", "How do goroutines communicate?")
+	history.Push("coding", "assistant", "Goroutines communicate over channels.")
+	history.Push("writing", "user", "Suggest a title about channels and rivers")
+	history.Push("writing", "assistant", "Rivers of Thought")
+
+	t.Run("All Terms", func(t *testing.T) {
+		results, err := history.Search("goroutines channels", utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Role != "assistant" || results[0].ID == 0 {
+			t.Errorf("Expected the assistant answer about goroutines, got %+v", results)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		results, err := history.Search("channels", utils.SearchOptions{Assistant: "writing", Role: "user"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Assistant != "writing" {
+			t.Errorf("Expected one match of 'writing', got %+v", results)
+		}
+
+		results, err = history.Search("channels", utils.SearchOptions{Since: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("Expected no matches in the future, got %d", len(results))
+		}
+	})
+
+	t.Run("Highlight", func(t *testing.T) {
+		results, err := history.Search("rivers", utils.SearchOptions{Role: "assistant", HighlightStart: "[", HighlightEnd: "]"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || !strings.Contains(results[0].Snippet, "[Rivers]") {
+			t.Errorf("Expected highlighted snippet, got %+v", results)
+		}
+	})
+
+	t.Run("Deleted Records", func(t *testing.T) {
+		if err := history.Clear("writing"); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		results, err := history.Search("rivers", utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("Expected cleared records to be gone from search, got %d", len(results))
+		}
+	})
+}
+
+func TestSearchIndex(t *testing.T) {
+	dir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+	if !history.FullTextSearch() {
+		t.Skip("SQLite built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	history.Push("coding", "user", "Explain the deadlock in this worker pool")
+	history.Push("coding", "assistant", "The deadlock happens because the deadlock detector waits on the pool: a deadlock.")
+	history.Push("coding", "user", `Why does "go vet" complain about copylocks?`)
+
+	t.Run("Ranked Matches", func(t *testing.T) {
+		results, err := history.Search("deadlock", utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 2 || results[0].Role != "assistant" {
+			t.Errorf("Expected the message mentioning deadlock most to rank first, got %+v", results)
+		}
+	})
+
+	t.Run("Query Syntax Is Quoted", func(t *testing.T) {
+		results, err := history.Search(`"go vet" copylocks? NOT`, utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("Expected NOT to be a plain term, got %+v", results)
+		}
+		results, err = history.Search(`vet" copylocks?`, utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Expected operators and quotes in terms to be searched as text, got %+v", results)
+		}
+	})
+
+	t.Run("Snippet", func(t *testing.T) {
+		filler := strings.Repeat("lorem ipsum ", 40)
+		history.Push("shopping", "user", filler+"Buy ÄPFEL\nand Straße maps"+filler)
+		results, err := history.Search("äpfel", utils.SearchOptions{HighlightStart: "[", HighlightEnd: "]"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("Expected a case-insensitive match of non-ASCII text, got %+v", results)
+		}
+		snippet := results[0].Snippet
+		if !strings.Contains(snippet, "Buy [ÄPFEL] and Straße") || !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+			t.Errorf("Expected a one-line snippet around the highlighted match, got %q", snippet)
+		}
+	})
+
+	t.Run("Index Follows History", func(t *testing.T) {
+		history.Push("writing", "user", "A poem about a deadlock between two rivers")
+		results, err := history.Search("deadlock rivers", utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Assistant != "writing" {
+			t.Errorf("Expected a record added after the last search to be found, got %+v", results)
+		}
+
+		if err := history.Clear("coding"); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		history.Push("coding", "user", "No more deadlock")
+		results, err = history.Search("deadlock", utils.SearchOptions{Assistant: "coding"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Content != "No more deadlock" {
+			t.Errorf("Expected only the record written after clearing, got %+v", results)
+		}
+	})
+
+	t.Run("Reopened", func(t *testing.T) {
+		reopened, err := utils.NewHistory()
+		if err != nil {
+			t.Fatalf("Failed to reopen history: %v", err)
+		}
+		defer reopened.Close()
+		results, err := reopened.Search("deadlock", utils.SearchOptions{})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Expected the index to be kept between opens, got %+v", results)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"llm_cli/utils"
+)
+
+func TestSessions(t *testing.T) {
+	// Create temporary test directory
+	tmpDir, err := os.MkdirTemp("", "session_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Set HOME environment variable to use temp directory
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	t.Run("Default Session", func(t *testing.T) {
+		session, err := history.CurrentSession("test-assistant")
+		if err != nil {
+			t.Fatalf("CurrentSession failed: %v", err)
+		}
+		if session != utils.DefaultSession {
+			t.Errorf("Expected session '%s', got '%s'", utils.DefaultSession, session)
+		}
+
+		if err := history.Push("test-assistant", "user", "first thread"); err != nil {
+			t.Errorf("Push failed: %v", err)
+		}
+	})
+
+	t.Run("New Session", func(t *testing.T) {
+		name, err := history.NewSession("test-assistant", "feature-x")
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		if name != "feature-x" {
+			t.Errorf("Expected session 'feature-x', got '%s'", name)
+		}
+
+		if _, err := history.NewSession("test-assistant", "feature-x"); err == nil {
+			t.Error("Expected error creating duplicate session")
+		}
+
+		history.Push("test-assistant", "user", "second thread")
+
+		records, err := history.FetchSession("test-assistant", "feature-x", 10)
+		if err != nil {
+			t.Fatalf("FetchSession failed: %v", err)
+		}
+		if len(records) != 1 || records[0].Content != "second thread" {
+			t.Errorf("Expected only the new session's record, got %+v", records)
+		}
+
+		// Fetch still returns records across all sessions
+		records, err = history.Fetch("test-assistant", 10)
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if len(records) != 2 {
+			t.Errorf("Expected 2 records across sessions, got %d", len(records))
+		}
+	})
+
+	t.Run("Resume Session", func(t *testing.T) {
+		if err := history.UseSession("test-assistant", utils.DefaultSession); err != nil {
+			t.Fatalf("UseSession failed: %v", err)
+		}
+
+		session, _ := history.CurrentSession("test-assistant")
+		if session != utils.DefaultSession {
+			t.Errorf("Expected session '%s', got '%s'", utils.DefaultSession, session)
+		}
+
+		sessions, err := history.ListSessions("test-assistant")
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+		}
+		for _, s := range sessions {
+			if s.Current != (s.Name == utils.DefaultSession) {
+				t.Errorf("Unexpected current flag %v for session '%s'", s.Current, s.Name)
+			}
+			if s.Messages != 1 {
+				t.Errorf("Expected 1 message in session '%s', got %d", s.Name, s.Messages)
+			}
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		if err := history.Clear("test-assistant"); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+
+		sessions, err := history.ListSessions("test-assistant")
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("Expected 0 sessions after clear, got %d", len(sessions))
+		}
+	})
+}
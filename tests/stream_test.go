@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"llm_cli/llm/api"
+)
+
+// newSSEServer streams the given pieces of a server-sent event stream,
+// flushing after each one so that the client receives them separately
+func newSSEServer(pieces ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			fmt.Fprint(w, piece)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+// delta is the data line of a chunk carrying content
+func delta(content string) string {
+	return fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", content)
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   string
+		deltas int
+		usage  api.Usage
+		err    string
+	}{
+		{
+			name:   "Events Split Across Reads",
+			pieces: []string{`data: {"choices":[{"delta":{"content":"Hel`, `lo"}}]}` + "\n", "\n" + delta(" world")[:10], delta(" world")[10:], "data: [DONE]\n\n"},
+			want:   "Hello world",
+			deltas: 2,
+		},
+		{
+			name:   "Comments And Event Names Are Skipped",
+			pieces: []string{": keep-alive\n\n", "event: chunk\n" + delta("hi"), "data:\n\n", "data: [DONE]\n\n"},
+			want:   "hi",
+			deltas: 1,
+		},
+		{
+			name:   "Done Ends The Stream",
+			pieces: []string{delta("kept"), "data: [DONE]\n\n", delta(" ignored")},
+			want:   "kept",
+			deltas: 1,
+		},
+		{
+			name:   "Stream Without Done",
+			pieces: []string{delta("a"), delta("b")},
+			want:   "ab",
+			deltas: 2,
+		},
+		{
+			name:   "Usage Chunk",
+			pieces: []string{delta("hi"), `data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1}}` + "\n\n", "data: [DONE]\n\n"},
+			want:   "hi",
+			deltas: 1,
+			usage:  api.Usage{PromptTokens: 3, CompletionTokens: 1},
+		},
+		{
+			name:   "Error Event",
+			pieces: []string{delta("partial"), `data: {"error":{"message":"overloaded"}}` + "\n\n"},
+			err:    "API error: overloaded",
+		},
+		{
+			name:   "Invalid Chunk",
+			pieces: []string{"data: {not json\n\n"},
+			err:    "failed to parse stream chunk",
+		},
+		{
+			name:   "Empty Stream",
+			pieces: []string{"data: [DONE]\n\n"},
+			err:    "no response content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSSEServer(tt.pieces...)
+			defer server.Close()
+
+			enabled := true
+			req := api.Request{
+				Model:       "mock",
+				Messages:    []api.Message{{Role: "user", Content: "hi"}},
+				BaseURL:     server.URL,
+				MaxAttempts: 1,
+				StreamUsage: &enabled,
+			}
+			var deltas []string
+			response, err := api.Providers["OpenAICompatible"].(api.StreamProvider).Stream(req, func(delta string) {
+				deltas = append(deltas, delta)
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Stream failed: %v", err)
+			}
+			if response.Content != tt.want || strings.Join(deltas, "") != tt.want {
+				t.Errorf("Expected %q, got %q from deltas %q", tt.want, response.Content, deltas)
+			}
+			if len(deltas) != tt.deltas {
+				t.Errorf("Expected %d deltas, got %d: %q", tt.deltas, len(deltas), deltas)
+			}
+			if response.Usage != tt.usage {
+				t.Errorf("Expected usage %+v, got %+v", tt.usage, response.Usage)
+			}
+		})
+	}
+}
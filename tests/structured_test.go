@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/utils"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := utils.ParseJSONSchema([]byte(`{
+		"type": "object",
+		"required": ["name", "tags"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}},
+			"kind": {"enum": ["a", "b"]}
+		},
+		"$defs": {"tag": {"type": "string", "pattern": "^[a-z]+$"}}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"Valid", `{"name": "x", "age": 3, "tags": ["go"], "kind": "a"}`, ""},
+		{"Invalid JSON", `{"name": `, "invalid JSON"},
+		{"Trailing Data", `{"name": "x", "tags": []} {}`, "invalid JSON"},
+		{"Missing Required", `{"name": "x"}`, `$: missing required property "tags"`},
+		{"Wrong Type", `{"name": 1, "tags": []}`, "$.name: expected string, got integer"},
+		{"Not Integer", `{"name": "x", "age": 1.5, "tags": []}`, "$.age: expected integer, got number"},
+		{"Minimum", `{"name": "x", "age": -1, "tags": []}`, "$.age: -1 is less than minimum 0"},
+		{"Ref Pattern", `{"name": "x", "tags": ["ok", "Bad"]}`, `$.tags[1]: "Bad" does not match pattern`},
+		{"Enum", `{"name": "x", "tags": [], "kind": "c"}`, `$.kind: "c" is not one of ["a","b"]`},
+		{"Additional Property", `{"name": "x", "tags": [], "extra": true}`, `$: property "extra" is not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tt.value))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Expected valid, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("Nil Schema", func(t *testing.T) {
+		var none *utils.JSONSchema
+		if err := none.Validate([]byte(`[1, "two"]`)); err != nil {
+			t.Errorf("Expected any JSON to be valid, got %v", err)
+		}
+		if err := none.Validate([]byte(`not json`)); err == nil {
+			t.Error("Expected error for invalid JSON")
+		}
+	})
+}
+
+// newReplyServer answers chat completions with the given replies in turn and
+// records the response_format of each request
+func newReplyServer(t *testing.T, replies []string, formats *[]string) *httptest.Server {
+	calls := 0
+	return newChatServer(t, func(req chatRequest) (int, string) {
+		format := ""
+		if req.ResponseFormat != nil {
+			format = req.ResponseFormat.Type
+		}
+		*formats = append(*formats, format)
+
+		reply := replies[calls%len(replies)]
+		calls++
+		return http.StatusOK, chatReply(reply)
+	})
+}
+
+func TestJSONOutput(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "structured_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	schema, err := utils.ParseJSONSchema([]byte(`{"type": "object", "required": ["answer"]}`))
+	if err != nil {
+		t.Fatalf("ParseJSONSchema failed: %v", err)
+	}
+
+	enabled := true
+	structured := &enabled
+	run := func(replies ...string) (string, []string, error) {
+		var formats []string
+		server := newReplyServer(t, replies, &formats)
+		defer server.Close()
+
+		config.SetConfig(&config.Config{
+			Models: map[string]config.ModelConfig{
+				"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1, StructuredOutputs: structured},
+			},
+		})
+		response, err := llm.SimpleCallWithOptions("mock", "what is 6*7?", llm.CallOptions{
+			JSON: &llm.JSONOutput{Schema: schema},
+		})
+		return response, formats, err
+	}
+
+	t.Run("Valid Reply", func(t *testing.T) {
+		response, formats, err := run("```json\n{\"answer\": 42}\n```")
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response != `{"answer": 42}` {
+			t.Errorf("Expected bare JSON, got %q", response)
+		}
+		if len(formats) != 1 || formats[0] != "json_schema" {
+			t.Errorf("Expected a json_schema response format, got %v", formats)
+		}
+	})
+
+	t.Run("JSON Mode Without Structured Outputs", func(t *testing.T) {
+		structured = nil
+		defer func() { structured = &enabled }()
+		_, formats, err := run(`{"answer": 42}`)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if len(formats) != 1 || formats[0] != "json_object" {
+			t.Errorf("Expected a json_object response format, got %v", formats)
+		}
+	})
+
+	t.Run("Retry On Invalid Reply", func(t *testing.T) {
+		response, formats, err := run(`{"result": 42}`, `{"answer": 42}`)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if response != `{"answer": 42}` {
+			t.Errorf("Expected corrected JSON, got %q", response)
+		}
+		if len(formats) != 2 {
+			t.Errorf("Expected 2 calls, got %d", len(formats))
+		}
+	})
+
+	t.Run("Give Up", func(t *testing.T) {
+		_, formats, err := run("forty-two")
+		if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Errorf("Expected error after 3 attempts, got %v", err)
+		}
+		if len(formats) != 3 {
+			t.Errorf("Expected 3 calls, got %d", len(formats))
+		}
+	})
+}
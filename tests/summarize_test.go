@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/utils"
+)
+
+func TestSummarize(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "summarize_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	// Summary requests are answered with a fixed summary, chat requests with
+	// the number of messages received and whether the summary was included
+	server := newChatServer(t, func(req chatRequest) (int, string) {
+		reply := fmt.Sprintf("%d messages", len(req.Messages))
+		if strings.Contains(req.Messages[0].Content, "long-term memory") {
+			reply = "user likes Go"
+		} else if len(req.Messages) > 1 && strings.Contains(req.Messages[1].Content, "user likes Go") {
+			reply += " with summary"
+		}
+		return http.StatusOK, chatReply(reply)
+	})
+	defer server.Close()
+
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"memo": {
+				Model:             "mock",
+				Prompt:            "Be brief.",
+				ChatContextWindow: 10,
+				Summarize:         &config.SummarizeConfig{After: 4, Keep: 2},
+			},
+		},
+	})
+
+	var response string
+	for i := 0; i < 4; i++ {
+		response, err = llm.AssistantCall("memo", fmt.Sprintf("message %d", i))
+		if err != nil {
+			t.Fatalf("AssistantCall %d failed: %v", i, err)
+		}
+	}
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	summary, err := history.GetSummary("memo", utils.DefaultSession)
+	if err != nil {
+		t.Fatalf("GetSummary failed: %v", err)
+	}
+	if summary.Content != "user likes Go" || summary.LastID == 0 {
+		t.Errorf("Expected summary to be stored, got %+v", summary)
+	}
+
+	// System prompt, summary, the 4 messages since the summary and the input
+	response, err = llm.AssistantCall("memo", "what do I like?")
+	if err != nil {
+		t.Fatalf("AssistantCall failed: %v", err)
+	}
+	if response != "7 messages with summary" {
+		t.Errorf("Expected summary to replace older messages, got %q", response)
+	}
+}
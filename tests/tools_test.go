@@ -0,0 +1,361 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm_cli/config"
+	"llm_cli/llm"
+	"llm_cli/llm/tools"
+	"llm_cli/utils"
+)
+
+// chdir changes into dir for the rest of the test
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestBuiltinTools(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo: write tests\n"), 0644)
+	chdir(t, dir)
+
+	available, err := tools.ForAssistant(config.AssistantConfig{
+		Tools:           []string{"read_file", "list_directory", "grep", "run_command"},
+		AllowedCommands: []string{"echo"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("ForAssistant failed: %v", err)
+	}
+
+	tests := []struct {
+		tool      string
+		arguments string
+		want      string
+		err       string
+	}{
+		{"read_file", `{"path": "notes.txt"}`, "todo: write tests\n", ""},
+		{"read_file", `{"path": "../outside.txt"}`, "", "outside the working directory"},
+		{"read_file", `{"path": "src"}`, "", "is a directory"},
+		{"list_directory", `{}`, "notes.txt\nsrc/", ""},
+		{"grep", `{"pattern": "func \\w+"}`, "src/main.go:3: func main() {}", ""},
+		{"grep", `{"pattern": "nothing here"}`, "No matches", ""},
+		{"run_command", `{"command": "echo 'a  b' c"}`, "a  b c\n", ""},
+		{"run_command", `{"command": "rm -rf src"}`, "", "'rm' is not an allowed command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool+" "+tt.arguments, func(t *testing.T) {
+			result, err := available[tt.tool].Run(tt.arguments)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, result)
+			}
+		})
+	}
+
+	t.Run("Unknown Tool", func(t *testing.T) {
+		if _, err := tools.ForAssistant(config.AssistantConfig{Tools: []string{"delete_everything"}}, nil); err == nil {
+			t.Error("Expected error for unknown tool")
+		}
+	})
+}
+
+func TestDeclaredTools(t *testing.T) {
+	declared := map[string]config.ToolConfig{
+		"jira_lookup": {
+			Description: "Look up a Jira issue",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"id":{"type":"string"},"full":{"type":"boolean"}},"required":["id"]}`),
+			Command:     "printf '%s|' {{id}} {{ full }} {{missing}}",
+		},
+		"failing": {Command: "echo oops >&2; exit 3"},
+		"slow":    {Command: "sleep 5", Timeout: 1},
+		"grep":    {Command: "true"},
+	}
+	available, err := tools.ForAssistant(config.AssistantConfig{Tools: []string{"jira_lookup", "failing", "slow"}}, declared)
+	if err != nil {
+		t.Fatalf("ForAssistant failed: %v", err)
+	}
+
+	t.Run("Definition", func(t *testing.T) {
+		definition := available["jira_lookup"].Definition()
+		if definition.Name != "jira_lookup" || definition.Description != "Look up a Jira issue" || !strings.Contains(string(definition.Parameters), `"required":["id"]`) {
+			t.Errorf("Unexpected definition: %+v", definition)
+		}
+	})
+
+	t.Run("Arguments Are Quoted", func(t *testing.T) {
+		output, err := available["jira_lookup"].Run(`{"id": "PROJ-1; echo it's injected", "full": true}`)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if output != "PROJ-1; echo it's injected|true||" {
+			t.Errorf("Unexpected output %q", output)
+		}
+	})
+
+	t.Run("Quoted Placeholders Are Not Injectable", func(t *testing.T) {
+		dir := t.TempDir()
+		quoted := map[string]config.ToolConfig{
+			"lookup": {Command: `printf '%s|' "{{id}}" "id={{ id }}, \"{{full}}\"" {{id}}x`},
+		}
+		available, err := tools.ForAssistant(config.AssistantConfig{Tools: []string{"lookup"}}, quoted)
+		if err != nil {
+			t.Fatalf("ForAssistant failed: %v", err)
+		}
+		pwned := filepath.Join(dir, "pwned")
+		id := fmt.Sprintf("$(touch %s)`touch %s`\" ; touch %s #", pwned, pwned, pwned)
+		arguments, _ := json.Marshal(map[string]interface{}{"id": id, "full": "a b"})
+		output, err := available["lookup"].Run(string(arguments))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if want := id + "|id=" + id + `, "a b"|` + id + "x|"; output != want {
+			t.Errorf("Expected arguments as literal text %q, got %q", want, output)
+		}
+		if _, err := os.Stat(pwned); err == nil {
+			t.Error("Expected no command in an argument to run")
+		}
+	})
+
+	t.Run("Placeholder In Single Quotes", func(t *testing.T) {
+		quoted := map[string]config.ToolConfig{"lookup": {Command: "echo '{{id}}'"}}
+		_, err := tools.ForAssistant(config.AssistantConfig{Tools: []string{"lookup"}}, quoted)
+		if err == nil || !strings.Contains(err.Error(), "{{id}} inside single quotes") {
+			t.Errorf("Expected error for a placeholder in single quotes, got %v", err)
+		}
+	})
+
+	t.Run("Arguments Are Validated", func(t *testing.T) {
+		_, err := available["jira_lookup"].Run(`{"full": true}`)
+		if err == nil || !strings.Contains(err.Error(), `missing required property "id"`) {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		_, err := available["failing"].Run(`{}`)
+		if err == nil || !strings.Contains(err.Error(), "exit status 3: oops") {
+			t.Errorf("Expected exit status and stderr, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := available["slow"].Run(`{}`)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+			t.Errorf("Expected timeout, got %v", err)
+		}
+	})
+
+	t.Run("Built-in Name", func(t *testing.T) {
+		if _, err := tools.ForAssistant(config.AssistantConfig{Tools: []string{"grep"}}, declared); err == nil {
+			t.Error("Expected error for a declared tool named like a built-in one")
+		}
+	})
+}
+
+// newToolServer asks for one tool call and then answers with the tool
+// results it was sent
+func newToolServer(t *testing.T, name, arguments string) *httptest.Server {
+	return newChatServer(t, func(req chatRequest) (int, string) {
+		if len(req.Tools) == 0 {
+			t.Error("Expected tools in request")
+		}
+
+		last := req.LastMessage()
+		if last.Role != "tool" {
+			return http.StatusOK, chatToolCall(name, arguments)
+		}
+		if last.ToolCallID != "call_1" {
+			t.Errorf("Expected tool_call_id call_1, got %q", last.ToolCallID)
+		}
+		return http.StatusOK, chatReply("tool said: " + last.Content)
+	})
+}
+
+func TestAssistantToolCalls(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("buy milk"), 0644)
+	chdir(t, dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	setup := func(server *httptest.Server) {
+		config.SetConfig(&config.Config{
+			Models: map[string]config.ModelConfig{
+				"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1},
+			},
+			Assistants: map[string]config.AssistantConfig{
+				"agent": {
+					Model:           "mock",
+					Prompt:          "Use tools.",
+					Tools:           []string{"read_file", "run_command"},
+					AllowedCommands: []string{"echo"},
+				},
+			},
+		})
+	}
+
+	t.Run("Read File", func(t *testing.T) {
+		server := newToolServer(t, "read_file", `{"path":"notes.txt"}`)
+		defer server.Close()
+		setup(server)
+
+		response, err := llm.AssistantCall("agent", "what is in my notes?")
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if response != "tool said: buy milk" {
+			t.Errorf("Expected tool result in answer, got %q", response)
+		}
+	})
+
+	t.Run("Declined Command", func(t *testing.T) {
+		server := newToolServer(t, "run_command", `{"command":"echo hi"}`)
+		defer server.Close()
+		setup(server)
+
+		var prompts []string
+		response, err := llm.AssistantCallWithOptions("agent", "say hi", llm.AssistantOptions{
+			Confirm: func(prompt string) bool {
+				prompts = append(prompts, prompt)
+				return false
+			},
+		})
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if len(prompts) != 1 || !strings.Contains(prompts[0], "echo hi") {
+			t.Errorf("Expected one confirmation prompt for the command, got %q", prompts)
+		}
+		if !strings.Contains(response, "declined") {
+			t.Errorf("Expected the model to be told the call was declined, got %q", response)
+		}
+	})
+
+	t.Run("Confirmed Command", func(t *testing.T) {
+		server := newToolServer(t, "run_command", `{"command":"echo hi"}`)
+		defer server.Close()
+		setup(server)
+
+		response, err := llm.AssistantCallWithOptions("agent", "say hi", llm.AssistantOptions{
+			Confirm: func(string) bool { return true },
+		})
+		if err != nil {
+			t.Fatalf("AssistantCall failed: %v", err)
+		}
+		if response != "tool said: hi\n" {
+			t.Errorf("Expected command output in answer, got %q", response)
+		}
+	})
+
+	t.Run("Recorded In History", func(t *testing.T) {
+		history, err := utils.NewHistory()
+		if err != nil {
+			t.Fatalf("Failed to open history: %v", err)
+		}
+		defer history.Close()
+
+		calls, err := history.FetchToolCalls("agent", "", 10)
+		if err != nil {
+			t.Fatalf("FetchToolCalls failed: %v", err)
+		}
+		if len(calls) != 3 {
+			t.Fatalf("Expected 3 tool calls, got %d", len(calls))
+		}
+		if calls[0].Tool != "read_file" || calls[0].Output != "buy milk" || calls[0].Session != utils.DefaultSession {
+			t.Errorf("Unexpected first call: %+v", calls[0])
+		}
+		if !strings.Contains(calls[1].Error, "declined") {
+			t.Errorf("Expected declined call to be recorded with an error, got %+v", calls[1])
+		}
+		if calls[2].Arguments != `{"command":"echo hi"}` || calls[2].Output != "hi\n" {
+			t.Errorf("Unexpected last call: %+v", calls[2])
+		}
+	})
+}
+
+// newStreamingToolServer streams a reply asking for one tool call, then
+// streams an answer containing the tool result
+func newStreamingToolServer(t *testing.T, name, arguments string) *httptest.Server {
+	return newChatServer(t, func(req chatRequest) (int, string) {
+		if !req.Stream {
+			t.Error("Expected a streamed request")
+		}
+
+		last := req.LastMessage()
+		if last.Role != "tool" {
+			return http.StatusOK, chatEvents(chatDelta("Let me look."),
+				fmt.Sprintf(`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":%q,"arguments":%q}}]}}]}`, name, arguments))
+		}
+		return http.StatusOK, chatEvents(chatDelta("tool said: "), chatDelta(last.Content))
+	})
+}
+
+func TestAssistantToolCallsStreamed(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("buy milk"), 0644)
+	chdir(t, dir)
+
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := newStreamingToolServer(t, "read_file", `{"path":"notes.txt"}`)
+	defer server.Close()
+	config.SetConfig(&config.Config{
+		Models: map[string]config.ModelConfig{
+			"mock": {API: "OpenAICompatible", Model: "mock", BaseURL: server.URL, MaxAttempts: 1},
+		},
+		Assistants: map[string]config.AssistantConfig{
+			"agent": {Model: "mock", Prompt: "Use tools.", Tools: []string{"read_file"}},
+		},
+	})
+
+	var streamed []string
+	rounds := 0
+	response, err := llm.AssistantCallWithOptions("agent", "what is in my notes?", llm.AssistantOptions{
+		OnDelta: func(delta string) {
+			streamed = append(streamed, delta)
+		},
+		OnToolCalls: func() {
+			rounds++
+		},
+	})
+	if err != nil {
+		t.Fatalf("AssistantCall failed: %v", err)
+	}
+	if response != "tool said: buy milk" {
+		t.Errorf("Expected tool result in answer, got %q", response)
+	}
+	if strings.Join(streamed, "|") != "Let me look.|tool said: |buy milk" {
+		t.Errorf("Expected the content of both replies to be streamed, got %q", streamed)
+	}
+	if rounds != 1 {
+		t.Errorf("Expected OnToolCalls before the one round of tool calls, got %d", rounds)
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"llm_cli/config"
+	"llm_cli/llm/api"
+	"llm_cli/utils"
+)
+
+func TestUsage(t *testing.T) {
+	// Create temporary test directory
+	tmpDir, err := os.MkdirTemp("", "usage_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Set HOME environment variable to use temp directory
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", originalHome)
+
+	history, err := utils.NewHistory()
+	if err != nil {
+		t.Fatalf("Failed to create history: %v", err)
+	}
+	defer history.Close()
+
+	t.Run("Price", func(t *testing.T) {
+		price := &config.ModelPrice{Input: 2.5, Output: 10}
+		cost := price.Cost(api.Usage{PromptTokens: 1000, CompletionTokens: 500})
+		if cost != 0.0075 {
+			t.Errorf("Expected cost 0.0075, got %g", cost)
+		}
+
+		var unpriced *config.ModelPrice
+		if cost := unpriced.Cost(api.Usage{PromptTokens: 1000}); cost != 0 {
+			t.Errorf("Expected unpriced model to be free, got %g", cost)
+		}
+	})
+
+	records := []utils.UsageRecord{
+		{Assistant: "coder", Model: "gpt4o", PromptTokens: 100, CompletionTokens: 50, Cost: 0.01},
+		{Assistant: "coder", Model: "claude", PromptTokens: 200, CompletionTokens: 20, Cost: 0.02},
+		{Model: "gpt4o", PromptTokens: 10, CompletionTokens: 5, Cost: 0.001},
+	}
+	for _, record := range records {
+		if err := history.AddUsage(record); err != nil {
+			t.Fatalf("AddUsage failed: %v", err)
+		}
+	}
+
+	t.Run("By Model", func(t *testing.T) {
+		summaries, err := history.UsageReport(time.Time{}, "model")
+		if err != nil {
+			t.Fatalf("UsageReport failed: %v", err)
+		}
+		if len(summaries) != 2 {
+			t.Fatalf("Expected 2 models, got %d", len(summaries))
+		}
+		gpt := summaries[1]
+		if gpt.Key != "gpt4o" || gpt.Calls != 2 || gpt.PromptTokens != 110 || gpt.CompletionTokens != 55 {
+			t.Errorf("Unexpected summary for gpt4o: %+v", gpt)
+		}
+	})
+
+	t.Run("By Assistant", func(t *testing.T) {
+		summaries, err := history.UsageReport(time.Time{}, "assistant")
+		if err != nil {
+			t.Fatalf("UsageReport failed: %v", err)
+		}
+		if len(summaries) != 2 || summaries[1].Key != "coder" || summaries[1].Calls != 2 {
+			t.Errorf("Expected direct calls and 2 calls of 'coder', got %+v", summaries)
+		}
+	})
+
+	t.Run("Since", func(t *testing.T) {
+		summaries, err := history.UsageReport(time.Now().Add(time.Hour), "day")
+		if err != nil {
+			t.Fatalf("UsageReport failed: %v", err)
+		}
+		if len(summaries) != 0 {
+			t.Errorf("Expected no usage in the future, got %+v", summaries)
+		}
+	})
+
+	t.Run("Invalid Grouping", func(t *testing.T) {
+		if _, err := history.UsageReport(time.Time{}, "week"); err == nil {
+			t.Error("Expected error for unknown grouping")
+		}
+	})
+}
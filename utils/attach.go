@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxAttachmentSize is the largest file that is attached
+	MaxAttachmentSize = 256 * 1024
+	// MaxAttachmentsSize bounds the combined size of all attached files
+	MaxAttachmentsSize = 1024 * 1024
+	// binarySniffSize is how much of a file is inspected to detect binaries
+	binarySniffSize = 8000
+)
+
+// Attachment is a text file attached to a prompt
+type Attachment struct {
+	Path    string
+	Content string
+}
+
+// ReadAttachments reads the files named by paths, which may be files,
+// directories or globs such as "src/**/*.go". Files reached through a
+// directory or glob are skipped if .gitignore excludes them; binary and
+// oversized files are skipped with a warning on stderr. Each file is
+// attached once, in the order given.
+func ReadAttachments(paths []string) ([]Attachment, error) {
+	ignore := LoadGitignore(".")
+	seen := make(map[string]bool)
+	var attachments []Attachment
+	total := 0
+
+	for _, pattern := range paths {
+		files, err := expandPath(pattern, ignore)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+
+		for _, file := range files {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", file, err)
+			}
+			if len(content) > MaxAttachmentSize {
+				fmt.Fprintf(os.Stderr, "Skipping %s: larger than %d KB\n", file, MaxAttachmentSize/1024)
+				continue
+			}
+			if IsBinary(content) {
+				fmt.Fprintf(os.Stderr, "Skipping %s: binary file\n", file)
+				continue
+			}
+
+			total += len(content)
+			if total > MaxAttachmentsSize {
+				return nil, fmt.Errorf("attached files exceed %d KB in total", MaxAttachmentsSize/1024)
+			}
+			attachments = append(attachments, Attachment{Path: filepath.ToSlash(file), Content: string(content)})
+		}
+	}
+	return attachments, nil
+}
+
+// expandPath returns the files named by a path, directory or glob
+func expandPath(pattern string, ignore *Gitignore) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("cannot attach %s: %v", pattern, err)
+		}
+		if !info.IsDir() {
+			// Explicitly named files are attached even if ignored
+			return []string{filepath.Clean(pattern)}, nil
+		}
+		return walkFiles(pattern, nil, ignore)
+	}
+
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+		var files []string
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() || ignore.Ignored(match, false) {
+				continue
+			}
+			files = append(files, match)
+		}
+		return files, nil
+	}
+
+	// Walk from the directory before the first wildcard and match the rest
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	root := "."
+	if i := strings.IndexAny(pattern, "*?["); i > 0 {
+		if j := strings.LastIndex(pattern[:i], "/"); j >= 0 {
+			root = pattern[:j]
+		}
+	}
+	re, err := regexp.Compile("^" + globRegexp(pattern) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	return walkFiles(root, re, ignore)
+}
+
+// ListFiles lists the files below root that .gitignore does not exclude
+func ListFiles(root string) ([]string, error) {
+	return walkFiles(root, nil, LoadGitignore("."))
+}
+
+// walkFiles lists the files below root that are not ignored and, if re is
+// set, whose slash-separated path matches it
+func walkFiles(root string, re *regexp.Regexp, ignore *Gitignore) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && ignore.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if re == nil || re.MatchString(filepath.ToSlash(path)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// IsBinary reports whether content looks like a binary file: it contains NUL
+// bytes or is not valid UTF-8
+func IsBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > binarySniffSize {
+		sniff = sniff[:binarySniffSize]
+		// Do not count a multi-byte character cut at the end as invalid
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(sniff); i++ {
+			sniff = sniff[:len(sniff)-1]
+		}
+	}
+	return bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(sniff)
+}
+
+// FormatAttachments renders attachments as fenced blocks labelled with their
+// paths. The fence is longer than any backtick run in the content so files
+// containing Markdown stay intact.
+func FormatAttachments(attachments []Attachment) string {
+	var b strings.Builder
+	for i, attachment := range attachments {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fence := "```"
+		for strings.Contains(attachment.Content, fence) {
+			fence += "`"
+		}
+		content := strings.TrimSuffix(attachment.Content, "\n")
+		fmt.Fprintf(&b, "%s%s\n%s\n%s\n", fence, attachment.Path, content, fence)
+	}
+	return b.String()
+}
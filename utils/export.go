@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExportRecord is a record as written by export and read by import
+type ExportRecord struct {
+	ID        int64     `json:"id,omitempty"`
+	Assistant string    `json:"assistant"`
+	Session   string    `json:"session"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Model     string    `json:"model,omitempty"`
+	Images    []string  `json:"images,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportRecords returns all records in chronological order, optionally
+// restricted to one assistant and/or session
+func (h *History) ExportRecords(assistant, session string) ([]ExportRecord, error) {
+	query := `
+		SELECT id, assistant, session, role, content, model, images, created_at
+		FROM conversations
+		WHERE (? = '' OR assistant = ?) AND (? = '' OR session = ?)
+		ORDER BY assistant, session, created_at, id;
+	`
+	rows, err := h.db.Query(query, assistant, assistant, session, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export records: %v", err)
+	}
+	defer rows.Close()
+
+	var records []ExportRecord
+	for rows.Next() {
+		var r ExportRecord
+		var images string
+		if err := rows.Scan(&r.ID, &r.Assistant, &r.Session, &r.Role, &r.Content, &r.Model, &images, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %v", err)
+		}
+		r.Images = decodeImages(images)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// ImportRecords stores exported records, keeping their sessions and
+// timestamps. Records already present with the same assistant, session,
+// role, content and time are skipped, so importing a dump twice is harmless.
+// It returns the number of records imported.
+func (h *History) ImportRecords(records []ExportRecord) (int, error) {
+	for i, r := range records {
+		if r.Assistant == "" || r.Role == "" {
+			return 0, fmt.Errorf("record %d: assistant and role are required", i+1)
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start import: %v", err)
+	}
+	defer tx.Rollback()
+
+	imported := 0
+	for _, r := range records {
+		if r.Session == "" {
+			r.Session = DefaultSession
+		}
+		createdAt := time.Now()
+		if !r.CreatedAt.IsZero() {
+			createdAt = r.CreatedAt
+		}
+		timestamp := createdAt.UTC().Format(sqliteTimeFormat)
+
+		var exists int
+		query := `
+			SELECT COUNT(*) FROM conversations
+			WHERE assistant = ? AND session = ? AND role = ? AND content = ? AND created_at = ?;
+		`
+		if err := tx.QueryRow(query, r.Assistant, r.Session, r.Role, r.Content, timestamp).Scan(&exists); err != nil {
+			return 0, fmt.Errorf("failed to check for duplicates: %v", err)
+		}
+		if exists > 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO sessions (assistant, name) VALUES (?, ?);`, r.Assistant, r.Session); err != nil {
+			return 0, fmt.Errorf("failed to create session: %v", err)
+		}
+		query = `
+			INSERT INTO conversations (assistant, session, role, content, model, images, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?);
+		`
+		if _, err := tx.Exec(query, r.Assistant, r.Session, r.Role, r.Content, r.Model, encodeImages(r.Images), timestamp); err != nil {
+			return 0, fmt.Errorf("failed to insert record: %v", err)
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %v", err)
+	}
+	return imported, nil
+}
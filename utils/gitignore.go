@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitignoreRule is one pattern line of a .gitignore file
+type gitignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Gitignore matches paths against the .gitignore files of a directory and
+// its parents up to the repository root. It supports the common syntax:
+// comments, "!" negation, trailing "/" for directories, leading "/" anchors
+// and the "*", "?" and "**" wildcards.
+type Gitignore struct {
+	// rules per directory, relative to which their patterns apply
+	dirs  []string
+	rules [][]gitignoreRule
+}
+
+// LoadGitignore reads the .gitignore files that apply to dir
+func LoadGitignore(dir string) *Gitignore {
+	g := &Gitignore{}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return g
+	}
+
+	for {
+		if rules := readGitignore(filepath.Join(abs, ".gitignore")); len(rules) > 0 {
+			// Parents first so that deeper files take precedence
+			g.dirs = append([]string{abs}, g.dirs...)
+			g.rules = append([][]gitignoreRule{rules}, g.rules...)
+		}
+		if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			break
+		}
+		abs = parent
+	}
+	return g
+}
+
+func readGitignore(path string) []gitignoreRule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []gitignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule gitignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// Patterns without an inner slash match at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if !anchored {
+			line = "**/" + line
+		}
+
+		re, err := regexp.Compile("^" + globRegexp(line) + "(/.*)?$")
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Ignored reports whether path is excluded. The .git directory is always ignored.
+func (g *Gitignore) Ignored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if filepath.Base(abs) == ".git" || strings.Contains(abs, string(filepath.Separator)+".git"+string(filepath.Separator)) {
+		return true
+	}
+
+	ignored := false
+	for i, dir := range g.dirs {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range g.rules[i] {
+			if !rule.re.MatchString(rel) {
+				continue
+			}
+			// A directory-only rule matches files only through their parent directories
+			if rule.dirOnly && !isDir && rule.re.FindStringSubmatch(rel)[1] == "" {
+				continue
+			}
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globRegexp translates a slash-separated glob with "**" support into a regular expression
+func globRegexp(pattern string) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		rest := string(runes[i:])
+		switch {
+		case strings.HasPrefix(rest, "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(rest, "**"):
+			b.WriteString(".*")
+			i++
+		case runes[i] == '*':
+			b.WriteString("[^/]*")
+		case runes[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	return b.String()
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -20,17 +21,34 @@ const (
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
 	`
+	createSessionsTable = `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			assistant TEXT NOT NULL,
+			name TEXT NOT NULL,
+			current INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(assistant, name)
+		);
+	`
 )
 
 type History struct {
 	db *sql.DB
+	// fts is true if SQLite was built with FTS5 and the search index is in use
+	fts bool
 }
 
 type Record struct {
 	ID        int64
 	Assistant string
+	Session   string
 	Role      string
 	Content   string
+	// Model is the configured model that produced an assistant response
+	Model string
+	// Images are the paths or URLs of images attached to a user message
+	Images []string
 }
 
 // NewHistory initializes the history database
@@ -54,13 +72,77 @@ func NewHistory() (*History, error) {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	// Create table if not exists
-	if _, err := db.Exec(createTable); err != nil {
+	// Create tables if not exists
+	for _, stmt := range []string{createTable, createSessionsTable, createUsageTable, createSummariesTable, createToolCallsTable} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create table: %v", err)
+		}
+	}
+
+	h := &History{db: db}
+
+	// Records written before sessions existed belong to the default session
+	if err := h.addColumn("conversations", "session", "TEXT NOT NULL DEFAULT '"+DefaultSession+"'"); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to create table: %v", err)
+		return nil, err
+	}
+	if err := h.addColumn("conversations", "model", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := h.addColumn("conversations", "images", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	backfill := `
+		INSERT OR IGNORE INTO sessions (assistant, name)
+		SELECT DISTINCT assistant, session FROM conversations;
+	`
+	if _, err := db.Exec(backfill); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sessions: %v", err)
 	}
 
-	return &History{db: db}, nil
+	if err := h.initSearch(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// addColumn adds a column to an existing table unless it is already present
+func (h *History) addColumn(table, column, definition string) error {
+	rows, err := h.db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)
+	if _, err := h.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %v", table, column, err)
+	}
+	return nil
 }
 
 // Close closes the database connection
@@ -68,29 +150,78 @@ func (h *History) Close() error {
 	return h.db.Close()
 }
 
-// Push adds a new record to the history
+// Push adds a new record to the current session of an assistant
 func (h *History) Push(assistant, role, content string) error {
+	session, err := h.CurrentSession(assistant)
+	if err != nil {
+		return err
+	}
+	return h.PushSession(assistant, session, role, content)
+}
+
+// PushSession adds a new record to a specific session, creating the session if needed
+func (h *History) PushSession(assistant, session, role, content string) error {
+	return h.Add(Record{Assistant: assistant, Session: session, Role: role, Content: content})
+}
+
+// Add stores a record, creating its session if needed. An empty session
+// means the assistant's current session.
+func (h *History) Add(r Record) error {
+	if r.Session == "" {
+		session, err := h.CurrentSession(r.Assistant)
+		if err != nil {
+			return err
+		}
+		r.Session = session
+	}
+	if err := h.ensureSession(r.Assistant, r.Session); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO conversations (assistant, role, content)
-		VALUES (?, ?, ?);
+		INSERT INTO conversations (assistant, session, role, content, model, images)
+		VALUES (?, ?, ?, ?, ?, ?);
 	`
-	_, err := h.db.Exec(query, assistant, role, content)
+	_, err := h.db.Exec(query, r.Assistant, r.Session, r.Role, r.Content, r.Model, encodeImages(r.Images))
 	if err != nil {
 		return fmt.Errorf("failed to insert record: %v", err)
 	}
 	return nil
 }
 
-// Fetch retrieves the most recent records for a specific assistant
+// Fetch retrieves the most recent records for a specific assistant across all sessions
 func (h *History) Fetch(assistant string, limit int) ([]Record, error) {
 	query := `
-		SELECT id, assistant, role, content
+		SELECT id, assistant, session, role, content, model, images
 		FROM conversations
 		WHERE assistant = ?
 		ORDER BY created_at DESC, id DESC
 		LIMIT ?;
 	`
-	rows, err := h.db.Query(query, assistant, limit)
+	return h.query(query, assistant, limit)
+}
+
+// FetchSession retrieves the most recent records of one session of an assistant
+func (h *History) FetchSession(assistant, session string, limit int) ([]Record, error) {
+	return h.FetchSessionAfter(assistant, session, 0, limit)
+}
+
+// FetchSessionAfter is FetchSession restricted to records newer than the
+// record with ID afterID
+func (h *History) FetchSessionAfter(assistant, session string, afterID int64, limit int) ([]Record, error) {
+	query := `
+		SELECT id, assistant, session, role, content, model, images
+		FROM conversations
+		WHERE assistant = ? AND session = ? AND id > ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?;
+	`
+	return h.query(query, assistant, session, afterID, limit)
+}
+
+// query runs a newest-first record query and returns the records in chronological order
+func (h *History) query(query string, args ...interface{}) ([]Record, error) {
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch records: %v", err)
 	}
@@ -99,9 +230,11 @@ func (h *History) Fetch(assistant string, limit int) ([]Record, error) {
 	var records []Record
 	for rows.Next() {
 		var r Record
-		if err := rows.Scan(&r.ID, &r.Assistant, &r.Role, &r.Content); err != nil {
+		var images string
+		if err := rows.Scan(&r.ID, &r.Assistant, &r.Session, &r.Role, &r.Content, &r.Model, &images); err != nil {
 			return nil, fmt.Errorf("failed to scan record: %v", err)
 		}
+		r.Images = decodeImages(images)
 		records = append(records, r)
 	}
 
@@ -113,15 +246,35 @@ func (h *History) Fetch(assistant string, limit int) ([]Record, error) {
 	return records, nil
 }
 
-// Clear removes all history for a specific assistant
+// encodeImages stores image references as a JSON list, or "" if there are none
+func encodeImages(images []string) string {
+	if len(images) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(images)
+	return string(data)
+}
+
+func decodeImages(images string) []string {
+	if images == "" {
+		return nil
+	}
+	var decoded []string
+	json.Unmarshal([]byte(images), &decoded)
+	return decoded
+}
+
+// Clear removes all history, sessions, summaries and tool calls for a specific assistant
 func (h *History) Clear(assistant string) error {
-	query := `
-		DELETE FROM conversations
-		WHERE assistant = ?;
-	`
-	_, err := h.db.Exec(query, assistant)
-	if err != nil {
-		return fmt.Errorf("failed to clear history: %v", err)
+	for _, query := range []string{
+		`DELETE FROM conversations WHERE assistant = ?;`,
+		`DELETE FROM sessions WHERE assistant = ?;`,
+		`DELETE FROM summaries WHERE assistant = ?;`,
+		`DELETE FROM tool_calls WHERE assistant = ?;`,
+	} {
+		if _, err := h.db.Exec(query, assistant); err != nil {
+			return fmt.Errorf("failed to clear history: %v", err)
+		}
 	}
 	return nil
 } 
\ No newline at end of file
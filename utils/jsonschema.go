@@ -0,0 +1,441 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONSchema is a JSON Schema used to validate replies locally. It supports
+// the keywords models are commonly asked to follow: type, enum, const,
+// properties, required, additionalProperties, items, the length, size and
+// range limits, pattern, allOf, anyOf, oneOf, not and local "$ref"s into
+// "$defs" or "definitions". Other keywords, such as format, are ignored.
+type JSONSchema struct {
+	// Raw is the schema as read, to be forwarded to providers
+	Raw  json.RawMessage
+	root interface{}
+}
+
+// LoadJSONSchema reads a JSON Schema from a file
+func LoadJSONSchema(path string) (*JSONSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	schema, err := ParseJSONSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %v", path, err)
+	}
+	return schema, nil
+}
+
+// ParseJSONSchema parses a JSON Schema
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	root, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("a schema must be an object or a boolean")
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, err
+	}
+	return &JSONSchema{Raw: compact.Bytes(), root: root}, nil
+}
+
+// Validate parses data as a single JSON value and checks it against the
+// schema. The error names the location of the first violation, e.g.
+// "$.items[2].name: expected string, got number". A nil schema accepts any
+// JSON value.
+func (s *JSONSchema) Validate(data []byte) error {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	if s == nil {
+		return nil
+	}
+	return s.validate(s.root, value, "$", 0)
+}
+
+// decodeJSON decodes exactly one JSON value, keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// maxSchemaDepth stops recursive "$ref"s that never reach a value
+const maxSchemaDepth = 64
+
+func (s *JSONSchema) validate(schema, value interface{}, path string, depth int) error {
+	if depth > maxSchemaDepth {
+		return fmt.Errorf("%s: schema nesting too deep", path)
+	}
+
+	rules, ok := schema.(map[string]interface{})
+	if !ok {
+		if schema == false {
+			return fmt.Errorf("%s: no value is allowed here", path)
+		}
+		return nil
+	}
+
+	if ref, ok := rules["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := s.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if types, ok := rules["type"]; ok {
+		if err := checkType(types, value, path); err != nil {
+			return err
+		}
+	}
+	if enum, ok := rules["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %s is not one of %s", path, encodeJSON(value), encodeJSON(enum))
+		}
+	}
+	if constant, ok := rules["const"]; ok && !jsonEqual(constant, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, encodeJSON(constant), encodeJSON(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if err := s.validateObject(rules, v, path, depth); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := s.validateArray(rules, v, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := validateString(rules, v, path); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := validateNumber(rules, v, path); err != nil {
+			return err
+		}
+	}
+
+	return s.validateCombinators(rules, value, path, depth)
+}
+
+func (s *JSONSchema) validateObject(rules map[string]interface{}, object map[string]interface{}, path string, depth int) error {
+	if required, ok := rules["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := object[key]; !present {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+	if err := checkCount(rules, "minProperties", "maxProperties", len(object), "properties", path); err != nil {
+		return err
+	}
+
+	properties, _ := rules["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Report violations in a stable order
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if property, ok := properties[key]; ok {
+			if err := s.validate(property, object[key], childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional, ok := rules["additionalProperties"]; ok {
+			if additional == false {
+				return fmt.Errorf("%s: property %q is not allowed", path, key)
+			}
+			if err := s.validate(additional, object[key], childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *JSONSchema) validateArray(rules map[string]interface{}, array []interface{}, path string, depth int) error {
+	if err := checkCount(rules, "minItems", "maxItems", len(array), "items", path); err != nil {
+		return err
+	}
+	if items, ok := rules["items"]; ok {
+		for i, item := range array {
+			if err := s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := rules["uniqueItems"].(bool); unique {
+		for i := range array {
+			for j := 0; j < i; j++ {
+				if jsonEqual(array[i], array[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", path, j, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(rules map[string]interface{}, value string, path string) error {
+	if err := checkCount(rules, "minLength", "maxLength", utf8.RuneCountInString(value), "characters", path); err != nil {
+		return err
+	}
+	if pattern, ok := rules["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q in schema: %v", path, pattern, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s: %q does not match pattern %q", path, value, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(rules map[string]interface{}, value json.Number, path string) error {
+	n, err := value.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %s", path, value)
+	}
+	limits := []struct {
+		keyword string
+		fails   func(n, limit float64) bool
+		message string
+	}{
+		{"minimum", func(n, limit float64) bool { return n < limit }, "less than"},
+		{"maximum", func(n, limit float64) bool { return n > limit }, "greater than"},
+		{"exclusiveMinimum", func(n, limit float64) bool { return n <= limit }, "not greater than"},
+		{"exclusiveMaximum", func(n, limit float64) bool { return n >= limit }, "not less than"},
+	}
+	for _, limit := range limits {
+		if bound, ok := schemaNumber(rules, limit.keyword); ok && limit.fails(n, bound) {
+			return fmt.Errorf("%s: %s is %s %s %g", path, value, limit.message, limit.keyword, bound)
+		}
+	}
+	if multiple, ok := schemaNumber(rules, "multipleOf"); ok && multiple > 0 {
+		if q := n / multiple; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: %s is not a multiple of %g", path, value, multiple)
+		}
+	}
+	return nil
+}
+
+func (s *JSONSchema) validateCombinators(rules map[string]interface{}, value interface{}, path string, depth int) error {
+	if all, ok := rules["allOf"].([]interface{}); ok {
+		for _, schema := range all {
+			if err := s.validate(schema, value, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := rules["anyOf"].([]interface{}); ok {
+		var first error
+		for _, schema := range anyOf {
+			err := s.validate(schema, value, path, depth+1)
+			if err == nil {
+				first = nil
+				break
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		if first != nil {
+			return fmt.Errorf("%s: matches none of anyOf (first: %v)", path, first)
+		}
+	}
+	if one, ok := rules["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, schema := range one {
+			if s.validate(schema, value, path, depth+1) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of oneOf, expected exactly 1", path, matches)
+		}
+	}
+	if not, ok := rules["not"]; ok && s.validate(not, value, path, depth+1) == nil {
+		return fmt.Errorf("%s: must not match the schema under \"not\"", path)
+	}
+	return nil
+}
+
+// resolve looks up a local reference such as "#/$defs/item"
+func (s *JSONSchema) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q, only local references are resolved", ref)
+	}
+
+	node := s.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+		if node, ok = object[part]; !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// checkType checks value against a "type" keyword, a name or a list of names
+func checkType(types interface{}, value interface{}, path string) error {
+	var names []string
+	switch t := types.(type) {
+	case string:
+		names = []string{t}
+	case []interface{}:
+		for _, name := range t {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	actual := jsonType(value)
+	for _, name := range names {
+		if name == actual || (name == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(names, " or "), actual)
+}
+
+// jsonType names the JSON Schema type of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkCount checks n against a pair of minimum and maximum count keywords
+func checkCount(rules map[string]interface{}, minKeyword, maxKeyword string, n int, unit, path string) error {
+	if lower, ok := schemaNumber(rules, minKeyword); ok && float64(n) < lower {
+		return fmt.Errorf("%s: has %d %s, fewer than %s %g", path, n, unit, minKeyword, lower)
+	}
+	if upper, ok := schemaNumber(rules, maxKeyword); ok && float64(n) > upper {
+		return fmt.Errorf("%s: has %d %s, more than %s %g", path, n, unit, maxKeyword, upper)
+	}
+	return nil
+}
+
+// schemaNumber returns a numeric keyword of a schema
+func schemaNumber(rules map[string]interface{}, keyword string) (float64, bool) {
+	number, ok := rules[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	n, err := number.Float64()
+	return n, err == nil
+}
+
+// jsonEqual compares decoded JSON values, treating 1 and 1.0 as equal
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// encodeJSON renders a decoded value for error messages
+func encodeJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OptionSpec describes how a long option is parsed into a T
+type OptionSpec[T any] struct {
+	// Value is true if the option requires a value ("--flag value" or "--flag=value")
+	Value bool
+	// Optional is true if the option accepts a value only in "--flag=value" form
+	Optional bool
+	Set      func(target *T, value string) error
+}
+
+// ParseOptions sets the options of specs found in args on target and returns
+// the remaining positional arguments. An argument "--" ends the options:
+// everything after it is positional, even if it looks like an option. If
+// stop is not nil, it is called before each positional argument with those
+// found so far; once it returns true, that argument and everything after it
+// are positional as well, like after the first operand of POSIX getopt.
+func ParseOptions[T any](args []string, target *T, specs map[string]OptionSpec[T], stop func(positional []string, arg string) bool) ([]string, error) {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			return append(rest, args[i+1:]...), nil
+		}
+		name, value, hasValue := strings.Cut(args[i], "=")
+		spec, known := specs[name]
+		if !known {
+			if stop != nil && stop(rest, args[i]) {
+				return append(rest, args[i:]...), nil
+			}
+			rest = append(rest, args[i])
+			continue
+		}
+
+		switch {
+		case hasValue && !spec.Value && !spec.Optional:
+			return nil, fmt.Errorf("option %s does not take a value", name)
+		case !hasValue && spec.Value:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("option %s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		if err := spec.Set(target, value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+	return rest, nil
+}
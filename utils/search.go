@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The FTS5 index mirrors conversations.content through triggers. FTS5 is
+// only available when go-sqlite3 is built with the sqlite_fts5 tag, which
+// the documented build uses; other builds cannot search.
+const (
+	createSearchIndex = `
+		CREATE VIRTUAL TABLE conversations_fts
+		USING fts5(content, content='conversations', content_rowid='id');
+		CREATE TRIGGER conversations_fts_insert AFTER INSERT ON conversations BEGIN
+			INSERT INTO conversations_fts(rowid, content) VALUES (new.id, new.content);
+		END;
+		CREATE TRIGGER conversations_fts_delete AFTER DELETE ON conversations BEGIN
+			INSERT INTO conversations_fts(conversations_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END;
+		INSERT INTO conversations_fts(conversations_fts) VALUES ('rebuild');
+	`
+
+	// snippetTokens is the number of tokens of context shown around a match
+	snippetTokens = 24
+)
+
+// ErrNoFullTextSearch is returned by Search when SQLite lacks FTS5
+var ErrNoFullTextSearch = errors.New("searching history needs SQLite with FTS5, build llmcli with -tags sqlite_fts5")
+
+// SearchOptions restricts a history search. Zero values match everything.
+type SearchOptions struct {
+	Assistant string
+	Role      string
+	Since     time.Time
+	Limit     int
+	// HighlightStart and HighlightEnd enclose matched terms in snippets
+	HighlightStart string
+	HighlightEnd   string
+}
+
+// SearchResult is a record matching a search
+type SearchResult struct {
+	Record
+	// CreatedAt is the local time of the record, e.g. "2024-06-01 14:30"
+	CreatedAt string
+	// Snippet is the part of the content around the match, with matched terms highlighted
+	Snippet string
+}
+
+// initSearch creates the full-text index and its triggers if FTS5 is
+// available and they do not exist yet. Without FTS5 the triggers of an
+// existing index would make every insert fail, so such a database is
+// refused rather than left half-indexed.
+func (h *History) initSearch() error {
+	var enabled bool
+	if err := h.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5');`).Scan(&enabled); err != nil {
+		return fmt.Errorf("failed to check for FTS5: %v", err)
+	}
+
+	var indexed bool
+	query := `SELECT COUNT(*) > 0 FROM sqlite_master WHERE name = 'conversations_fts';`
+	if err := h.db.QueryRow(query).Scan(&indexed); err != nil {
+		return fmt.Errorf("failed to inspect search index: %v", err)
+	}
+
+	switch {
+	case !enabled && indexed:
+		return fmt.Errorf("the history database has a search index but this build of llmcli lacks SQLite FTS5, build it with -tags sqlite_fts5")
+	case !enabled:
+		return nil
+	case !indexed:
+		if _, err := h.db.Exec(createSearchIndex); err != nil {
+			return fmt.Errorf("failed to create search index: %v", err)
+		}
+	}
+	h.fts = true
+	return nil
+}
+
+// FullTextSearch reports whether the history can be searched
+func (h *History) FullTextSearch() bool {
+	return h.fts
+}
+
+// Search returns the records whose content contains all terms of query,
+// best matches first. It returns ErrNoFullTextSearch without FTS5.
+func (h *History) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if !h.fts {
+		return nil, ErrNoFullTextSearch
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+
+	// Quote every term so that FTS5 operators are searched as text
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	args := []interface{}{opts.HighlightStart, opts.HighlightEnd, snippetTokens, strings.Join(quoted, " ")}
+	where := []string{"conversations_fts MATCH ?"}
+	if opts.Assistant != "" {
+		where = append(where, "c.assistant = ?")
+		args = append(args, opts.Assistant)
+	}
+	if opts.Role != "" {
+		where = append(where, "c.role = ?")
+		args = append(args, opts.Role)
+	}
+	if !opts.Since.IsZero() {
+		where = append(where, "c.created_at >= ?")
+		args = append(args, opts.Since.UTC().Format(sqliteTimeFormat))
+	}
+	args = append(args, opts.Limit)
+
+	rows, err := h.db.Query(fmt.Sprintf(`
+		SELECT c.id, c.assistant, c.session, c.role, c.content, c.model,
+			strftime('%%Y-%%m-%%d %%H:%%M', c.created_at, 'localtime'),
+			snippet(conversations_fts, 0, ?, ?, '…', ?)
+		FROM conversations_fts JOIN conversations c ON c.id = conversations_fts.rowid
+		WHERE %s
+		ORDER BY rank
+		LIMIT ?;
+	`, strings.Join(where, " AND ")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search history: %v", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.ID, &r.Assistant, &r.Session, &r.Role, &r.Content, &r.Model, &r.CreatedAt, &r.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %v", err)
+		}
+		// Show the snippet on one line
+		r.Snippet = strings.Join(strings.Fields(r.Snippet), " ")
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultSession is the session used when none has been started or resumed
+const DefaultSession = "default"
+
+// Session describes a named conversation thread of an assistant
+type Session struct {
+	Assistant string
+	Name      string
+	Current   bool
+	Messages  int
+	CreatedAt string
+	UpdatedAt string
+}
+
+// CurrentSession returns the session new messages of an assistant go to
+func (h *History) CurrentSession(assistant string) (string, error) {
+	query := `
+		SELECT name FROM sessions
+		WHERE assistant = ? AND current = 1
+		LIMIT 1;
+	`
+	var name string
+	err := h.db.QueryRow(query, assistant).Scan(&name)
+	if err == sql.ErrNoRows {
+		return DefaultSession, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get current session: %v", err)
+	}
+	return name, nil
+}
+
+// SessionExists reports whether an assistant has a session with the given name
+func (h *History) SessionExists(assistant, name string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM sessions WHERE assistant = ? AND name = ?;`
+	if err := h.db.QueryRow(query, assistant, name).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up session: %v", err)
+	}
+	return count > 0, nil
+}
+
+// NewSession creates a session and makes it current. An empty name is
+// replaced by a timestamp-based one; the name actually used is returned.
+func (h *History) NewSession(assistant, name string) (string, error) {
+	if name == "" {
+		name = "session-" + time.Now().Format("20060102-150405")
+	}
+
+	exists, err := h.SessionExists(assistant, name)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("session '%s' already exists for assistant '%s'", name, assistant)
+	}
+
+	if err := h.UseSession(assistant, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// UseSession makes a session current, creating it if it does not exist
+func (h *History) UseSession(assistant, name string) error {
+	if err := h.ensureSession(assistant, name); err != nil {
+		return err
+	}
+
+	query := `UPDATE sessions SET current = (name = ?) WHERE assistant = ?;`
+	if _, err := h.db.Exec(query, name, assistant); err != nil {
+		return fmt.Errorf("failed to switch session: %v", err)
+	}
+	return nil
+}
+
+// ClearSession removes the messages, summary and tool calls of one session
+// of an assistant. The session itself and the other sessions are kept.
+func (h *History) ClearSession(assistant, name string) error {
+	for _, query := range []string{
+		`DELETE FROM conversations WHERE assistant = ? AND session = ?;`,
+		`DELETE FROM summaries WHERE assistant = ? AND session = ?;`,
+		`DELETE FROM tool_calls WHERE assistant = ? AND session = ?;`,
+	} {
+		if _, err := h.db.Exec(query, assistant, name); err != nil {
+			return fmt.Errorf("failed to clear session: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListSessions returns all sessions of an assistant, most recently active first
+func (h *History) ListSessions(assistant string) ([]Session, error) {
+	query := `
+		SELECT s.assistant, s.name, s.current, COUNT(c.id),
+			s.created_at, COALESCE(MAX(c.created_at), s.created_at)
+		FROM sessions s
+		LEFT JOIN conversations c ON c.assistant = s.assistant AND c.session = s.name
+		WHERE s.assistant = ?
+		GROUP BY s.id
+		ORDER BY COALESCE(MAX(c.created_at), s.created_at) DESC, s.id DESC;
+	`
+	rows, err := h.db.Query(query, assistant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.Assistant, &s.Name, &s.Current, &s.Messages, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// ensureSession creates the session row if it does not exist yet
+func (h *History) ensureSession(assistant, name string) error {
+	query := `INSERT OR IGNORE INTO sessions (assistant, name) VALUES (?, ?);`
+	if _, err := h.db.Exec(query, assistant, name); err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const createSummariesTable = `
+	CREATE TABLE IF NOT EXISTS summaries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		assistant TEXT NOT NULL,
+		session TEXT NOT NULL,
+		content TEXT NOT NULL,
+		last_id INTEGER NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(assistant, session)
+	);
+`
+
+// Summary is the running summary of the older messages of a session
+type Summary struct {
+	Assistant string
+	Session   string
+	Content   string
+	// LastID is the ID of the newest record covered by the summary
+	LastID int64
+}
+
+// GetSummary returns the summary of a session, or an empty summary if the
+// session has not been summarized yet
+func (h *History) GetSummary(assistant, session string) (Summary, error) {
+	summary := Summary{Assistant: assistant, Session: session}
+	query := `SELECT content, last_id FROM summaries WHERE assistant = ? AND session = ?;`
+	err := h.db.QueryRow(query, assistant, session).Scan(&summary.Content, &summary.LastID)
+	if err != nil && err != sql.ErrNoRows {
+		return summary, fmt.Errorf("failed to fetch summary: %v", err)
+	}
+	return summary, nil
+}
+
+// SaveSummary stores the summary of a session, replacing any previous one
+func (h *History) SaveSummary(s Summary) error {
+	query := `
+		INSERT INTO summaries (assistant, session, content, last_id)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(assistant, session) DO UPDATE SET
+			content = excluded.content,
+			last_id = excluded.last_id,
+			updated_at = CURRENT_TIMESTAMP;
+	`
+	if _, err := h.db.Exec(query, s.Assistant, s.Session, s.Content, s.LastID); err != nil {
+		return fmt.Errorf("failed to save summary: %v", err)
+	}
+	return nil
+}
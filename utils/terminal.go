@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+)
+
+// IsTerminal reports whether the file is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// TerminalWidth returns the column count of the terminal behind f
+func TerminalWidth(f *os.File) int {
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil || width <= 0 {
+		return defaultTerminalWidth
+	}
+	return width
+}
+
+// TerminalHeight returns the row count of the terminal behind f
+func TerminalHeight(f *os.File) int {
+	_, height, err := term.GetSize(int(f.Fd()))
+	if err != nil || height <= 0 {
+		return defaultTerminalHeight
+	}
+	return height
+}
+
+// CountRows returns how many terminal rows the text occupies once wrapped at width
+func CountRows(text string, width int) int {
+	if width <= 0 {
+		width = defaultTerminalWidth
+	}
+
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		w := runewidth.StringWidth(line)
+		if w == 0 {
+			rows++
+			continue
+		}
+		rows += (w + width - 1) / width
+	}
+	return rows
+}
+
+// ClearRows moves the cursor up over the last n rows and erases them
+func ClearRows(f *os.File, n int) {
+	if n <= 0 {
+		return
+	}
+	if n > 1 {
+		fmt.Fprintf(f, "\033[%dA", n-1)
+	}
+	f.WriteString("\r\033[J")
+}
+
+// Confirm asks a yes/no question on the controlling terminal, so that it
+// works even when stdin is piped. Without a terminal the answer is no.
+func Confirm(prompt string) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
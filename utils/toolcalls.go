@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+const createToolCallsTable = `
+	CREATE TABLE IF NOT EXISTS tool_calls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		assistant TEXT NOT NULL,
+		session TEXT NOT NULL,
+		tool TEXT NOT NULL,
+		arguments TEXT NOT NULL,
+		output TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// ToolCallRecord is a tool an assistant ran and what it returned
+type ToolCallRecord struct {
+	ID        int64
+	Assistant string
+	Session   string
+	Tool      string
+	// Arguments is the JSON object of arguments written by the model
+	Arguments string
+	Output    string
+	// Error is set if the tool failed or the user declined the call
+	Error     string
+	CreatedAt time.Time
+}
+
+// AddToolCall stores a tool call
+func (h *History) AddToolCall(r ToolCallRecord) error {
+	query := `
+		INSERT INTO tool_calls (assistant, session, tool, arguments, output, error)
+		VALUES (?, ?, ?, ?, ?, ?);
+	`
+	if _, err := h.db.Exec(query, r.Assistant, r.Session, r.Tool, r.Arguments, r.Output, r.Error); err != nil {
+		return fmt.Errorf("failed to record tool call: %v", err)
+	}
+	return nil
+}
+
+// FetchToolCalls returns the most recent tool calls of an assistant in
+// chronological order, restricted to one session unless session is empty
+func (h *History) FetchToolCalls(assistant, session string, limit int) ([]ToolCallRecord, error) {
+	query := `
+		SELECT id, assistant, session, tool, arguments, output, error, created_at
+		FROM tool_calls
+		WHERE assistant = ? AND (? = '' OR session = ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?;
+	`
+	rows, err := h.db.Query(query, assistant, session, session, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tool calls: %v", err)
+	}
+	defer rows.Close()
+
+	var calls []ToolCallRecord
+	for rows.Next() {
+		var c ToolCallRecord
+		if err := rows.Scan(&c.ID, &c.Assistant, &c.Session, &c.Tool, &c.Arguments, &c.Output, &c.Error, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tool call: %v", err)
+		}
+		calls = append(calls, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch tool calls: %v", err)
+	}
+
+	for i := 0; i < len(calls)/2; i++ {
+		j := len(calls) - 1 - i
+		calls[i], calls[j] = calls[j], calls[i]
+	}
+	return calls, nil
+}
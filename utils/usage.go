@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	createUsageTable = `
+		CREATE TABLE IF NOT EXISTS usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			assistant TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+	// sqliteTimeFormat matches the UTC text written by CURRENT_TIMESTAMP
+	sqliteTimeFormat = "2006-01-02 15:04:05"
+)
+
+// UsageRecord is the token usage and cost of one model call. Assistant is
+// empty for direct model calls.
+type UsageRecord struct {
+	Assistant        string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	// Cost is in USD, computed from the model's price at the time of the call
+	Cost float64
+}
+
+// UsageSummary aggregates the usage records sharing a key
+type UsageSummary struct {
+	Key              string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// usageGroups maps the supported groupings of UsageReport to SQL expressions
+var usageGroups = map[string]string{
+	"model":     "model",
+	"assistant": "assistant",
+	"day":       "date(created_at)",
+}
+
+// AddUsage stores the usage of a model call
+func (h *History) AddUsage(r UsageRecord) error {
+	query := `
+		INSERT INTO usage (assistant, model, prompt_tokens, completion_tokens, cost)
+		VALUES (?, ?, ?, ?, ?);
+	`
+	_, err := h.db.Exec(query, r.Assistant, r.Model, r.PromptTokens, r.CompletionTokens, r.Cost)
+	if err != nil {
+		return fmt.Errorf("failed to insert usage: %v", err)
+	}
+	return nil
+}
+
+// UsageTotal sums the usage recorded since the given time for one model or
+// assistant, selected by "model" or "assistant"
+func (h *History) UsageTotal(since time.Time, by, key string) (UsageSummary, error) {
+	total := UsageSummary{Key: key}
+	if by != "model" && by != "assistant" {
+		return total, fmt.Errorf("cannot total usage by %q, use model or assistant", by)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM usage
+		WHERE %s = ? AND created_at >= ?;
+	`, by)
+	row := h.db.QueryRow(query, key, since.UTC().Format(sqliteTimeFormat))
+	if err := row.Scan(&total.Calls, &total.PromptTokens, &total.CompletionTokens, &total.Cost); err != nil {
+		return total, fmt.Errorf("failed to query usage: %v", err)
+	}
+	return total, nil
+}
+
+// UsageReport sums the usage recorded since the given time, grouped by
+// "model", "assistant" or "day". A zero since covers all records.
+func (h *History) UsageReport(since time.Time, by string) ([]UsageSummary, error) {
+	group, ok := usageGroups[by]
+	if !ok {
+		return nil, fmt.Errorf("cannot group usage by %q, use model, assistant or day", by)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+		FROM usage
+		WHERE created_at >= ?
+		GROUP BY %[1]s
+		ORDER BY %[1]s;
+	`, group)
+	rows, err := h.db.Query(query, since.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %v", err)
+	}
+	defer rows.Close()
+
+	var summaries []UsageSummary
+	for rows.Next() {
+		var s UsageSummary
+		if err := rows.Scan(&s.Key, &s.Calls, &s.PromptTokens, &s.CompletionTokens, &s.Cost); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %v", err)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}